@@ -0,0 +1,12 @@
+package model
+
+type Color struct {
+	Hex   string  `json:"hex"`
+	RGB   [3]int  `json:"rgb"`
+	Share float64 `json:"share"`
+}
+
+type Colors struct {
+	Dominant Color   `json:"dominant"`
+	Palette  []Color `json:"palette"`
+}
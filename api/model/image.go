@@ -1,22 +1,99 @@
 package model
 
 import (
+	"fmt"
 	"io"
 	"resizer/converter/image"
+	"time"
 )
 
+const MaxDPR = 4
+
 type ImageRequest struct {
 	Entity  string     `json:"entity"`
 	File    string     `json:"file"`
 	Width   int        `json:"width"`
 	Quality float32    `json:"quality"`
 	Type    image.Type `json:"type"`
+
+	// Height и Fit задаются пресетами, при нулевой высоте она считается по пропорциям
+	Height int       `json:"height"`
+	Fit    image.Fit `json:"fit"`
+
+	// DPR умножает целевые размеры, но не выходит за размер источника и лимиты
+	DPR float32 `json:"dpr"`
+
+	// FirstFrame отключает сохранение анимации: берется только первый кадр
+	FirstFrame bool `json:"first_frame"`
+
+	// Options переопределяют настройки кодировщика по умолчанию
+	Options image.Overrides `json:"-"`
+}
+
+// Validate checks width and quality bounds before the source image is fetched.
+func (r ImageRequest) Validate(limits image.Limits) error {
+	if err := limits.CheckWidth(r.Width); err != nil {
+		return err
+	}
+
+	if err := limits.CheckHeight(r.Height); err != nil {
+		return err
+	}
+
+	if r.DPR != 0 && (r.DPR < 1 || r.DPR > MaxDPR) {
+		return &image.LimitError{Code: image.CodeInvalidOption, Message: fmt.Sprintf("dpr must be between 1 and %d, got %g", MaxDPR, r.DPR)}
+	}
+
+	if r.Quality < 1 || r.Quality > 100 {
+		return &image.LimitError{Code: image.CodeInvalidQuality, Message: fmt.Sprintf("quality must be between 1 and 100, got %v", r.Quality)}
+	}
+
+	return r.Options.Check(r.Type)
+}
+
+// VariantKey uniquely identifies the rendition produced from the source file.
+func (r ImageRequest) VariantKey() string {
+	key := fmt.Sprintf("%d-%g-%s", r.Width, r.Quality, r.Type.String())
+	if r.Height > 0 {
+		key += fmt.Sprintf("-h%d-%s", r.Height, r.Fit.String())
+	}
+	if r.DPR > 1 {
+		key += fmt.Sprintf("-x%g", r.DPR)
+	}
+	if r.FirstFrame {
+		key += "-first"
+	}
+	if options := r.Options.Key(); options != "" {
+		key += "-" + options
+	}
+
+	return key
 }
 
 type ImageResponse struct {
 	Type               string
+	ContentType        string
 	ContentLength      int64
 	ContentDisposition string
+	ContentRange       string
+	ETag               string
+	LastModified       time.Time
+	NotModified        bool
+	Width              int
+	Height             int
+	Provenance         Provenance
 
 	Body io.Reader
 }
+
+const (
+	CacheHit  = "HIT"
+	CacheMiss = "MISS"
+)
+
+// Provenance - происхождение закешированного изображения: попадание в кеш, URL апстрима и время сохранения
+type Provenance struct {
+	Cache    string
+	Source   string
+	CachedAt time.Time
+}
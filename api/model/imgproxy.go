@@ -0,0 +1,290 @@
+package model
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"resizer/config"
+	"resizer/converter/image"
+)
+
+const (
+	CodeInvalidURL = "invalid_url"
+
+	imgproxyDefaultQuality = 80
+)
+
+// ImgproxyURL - разобранный путь в формате imgproxy: /<signature>/<options>/plain/<source>@<ext>
+// или /<signature>/<options>/<base64 source>.<ext>
+type ImgproxyURL struct {
+	Signature string
+	// SignedPath - часть пути после подписи, по которой она считается
+	SignedPath string
+	Source     string
+	Request    ImageRequest
+	// OnlyPresets означает, что размеры и качество заданы только пресетами
+	OnlyPresets bool
+}
+
+// ImageSource - источник изображения: оригинал из бакета или апстрим прокси
+type ImageSource struct {
+	Entity  string
+	File    string
+	Service ServiceName
+	Proxied bool
+}
+
+// ParseImgproxyPath разбирает путь imgproxy. Поддерживаются опции resize, size, resizing_type,
+// width, height, quality, dpr, format и preset с их короткими именами, остальные отклоняются
+func ParseImgproxyPath(rawPath string, presets config.Presets) (ImgproxyURL, error) {
+	signature, rest, ok := strings.Cut(strings.TrimPrefix(rawPath, "/"), "/")
+	if !ok || signature == "" {
+		return ImgproxyURL{}, invalidURL("missing signature or source")
+	}
+
+	u := ImgproxyURL{
+		Signature:   signature,
+		SignedPath:  "/" + rest,
+		Request:     ImageRequest{Quality: imgproxyDefaultQuality},
+		OnlyPresets: true,
+	}
+
+	var (
+		ext       string
+		formatSet bool
+	)
+
+	segments := strings.Split(rest, "/")
+	for idx, segment := range segments {
+		if segment == "plain" {
+			source := strings.Join(segments[idx+1:], "/")
+			if at := strings.LastIndex(source, "@"); at >= 0 {
+				source, ext = source[:at], source[at+1:]
+			}
+
+			unescaped, err := url.PathUnescape(source)
+			if err != nil {
+				return ImgproxyURL{}, invalidURL("invalid plain source: %v", err)
+			}
+			u.Source = unescaped
+			break
+		}
+
+		// Опции всегда содержат ':', а в base64url двоеточия нет, значит начался закодированный источник
+		if !strings.Contains(segment, ":") {
+			encoded := strings.Join(segments[idx:], "")
+			if dot := strings.LastIndex(encoded, "."); dot >= 0 {
+				encoded, ext = encoded[:dot], encoded[dot+1:]
+			}
+
+			decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+			if err != nil {
+				return ImgproxyURL{}, invalidURL("invalid base64 source: %v", err)
+			}
+			u.Source = string(decoded)
+			break
+		}
+
+		isFormat, err := u.applyOption(segment, presets)
+		if err != nil {
+			return ImgproxyURL{}, err
+		}
+		formatSet = formatSet || isFormat
+	}
+
+	if u.Source == "" {
+		return ImgproxyURL{}, invalidURL("missing source")
+	}
+
+	switch {
+	case ext != "":
+		if err := unmarshalImgproxyFormat(&u.Request.Type, ext); err != nil {
+			return ImgproxyURL{}, err
+		}
+	case !formatSet:
+		// Как и imgproxy, без явного формата сохраняем в формате источника, а если он неизвестен - в JPEG
+		if err := unmarshalImgproxyFormat(&u.Request.Type, strings.TrimPrefix(path.Ext(u.Source), ".")); err != nil {
+			u.Request.Type = image.JPEG
+		}
+	}
+
+	return u, nil
+}
+
+// applyOption применяет одну опцию вида name:arg1:arg2 и сообщает, задавала ли она формат
+func (u *ImgproxyURL) applyOption(segment string, presets config.Presets) (bool, error) {
+	name, rawArgs, _ := strings.Cut(segment, ":")
+	args := strings.Split(rawArgs, ":")
+	r := &u.Request
+
+	if name != "preset" && name != "pr" && name != "dpr" {
+		u.OnlyPresets = false
+	}
+
+	switch name {
+	case "resize", "rs":
+		if err := setResizingType(r, args[0]); err != nil {
+			return false, err
+		}
+		return false, setSize(r, args[1:])
+	case "size", "s":
+		return false, setSize(r, args)
+	case "resizing_type", "rt":
+		return false, setResizingType(r, args[0])
+	case "width", "w":
+		return false, setInt(&r.Width, "width", args[0])
+	case "height", "h":
+		return false, setInt(&r.Height, "height", args[0])
+	case "quality", "q":
+		quality, err := strconv.Atoi(args[0])
+		if err != nil {
+			return false, invalidURL("invalid quality %q", args[0])
+		}
+		// Нулевое качество в imgproxy означает качество по умолчанию
+		if quality > 0 {
+			r.Quality = float32(quality)
+		}
+		return false, nil
+	case "dpr":
+		dpr, err := strconv.ParseFloat(args[0], 32)
+		if err != nil {
+			return false, invalidURL("invalid dpr %q", args[0])
+		}
+		r.DPR = float32(dpr)
+		return false, nil
+	case "format", "f", "ext":
+		return true, unmarshalImgproxyFormat(&r.Type, args[0])
+	case "preset", "pr":
+		for _, presetName := range args {
+			preset, err := PresetRequest(r.Entity, r.File, presets, presetName)
+			if err != nil {
+				return false, err
+			}
+			r.Width, r.Height, r.Fit, r.Quality, r.Type, r.Options =
+				preset.Width, preset.Height, preset.Fit, preset.Quality, preset.Type, preset.Options
+		}
+		return true, nil
+	}
+
+	return false, invalidURL("unsupported option %q", name)
+}
+
+// setResizingType переводит типы imgproxy в режимы вписывания конвертера
+func setResizingType(r *ImageRequest, resizingType string) error {
+	switch resizingType {
+	case "":
+	case "fit":
+		r.Fit = image.FitContain
+	case "fill", "fill-down", "auto":
+		r.Fit = image.FitCover
+	case "force":
+		r.Fit = image.FitFill
+	default:
+		return invalidURL("unsupported resizing type %q", resizingType)
+	}
+
+	return nil
+}
+
+// setSize разбирает аргументы width:height:enlarge:extend. Увеличение и так разрешено,
+// а расширение холстом не поддерживается, поэтому последние два аргумента только проверяются
+func setSize(r *ImageRequest, args []string) error {
+	if len(args) > 0 {
+		if err := setInt(&r.Width, "width", args[0]); err != nil {
+			return err
+		}
+	}
+	if len(args) > 1 {
+		if err := setInt(&r.Height, "height", args[1]); err != nil {
+			return err
+		}
+	}
+	for _, flag := range args[min(len(args), 2):] {
+		if flag == "" {
+			continue
+		}
+		if _, err := strconv.ParseBool(flag); err != nil {
+			return invalidURL("invalid boolean %q", flag)
+		}
+	}
+
+	return nil
+}
+
+func setInt(dst *int, name, raw string) error {
+	if raw == "" {
+		return nil
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return invalidURL("invalid %s %q", name, raw)
+	}
+	*dst = v
+
+	return nil
+}
+
+func unmarshalImgproxyFormat(t *image.Type, ext string) error {
+	if ext == "jpg" {
+		ext = "jpeg"
+	}
+
+	if err := t.UnmarshalText([]byte(ext)); err != nil {
+		return invalidURL("unsupported format %q", ext)
+	}
+
+	return nil
+}
+
+// ResolveSource сопоставляет источник imgproxy с оригиналом в бакете (local:///, s3://) или с апстримом прокси
+func ResolveSource(source, bucket string) (ImageSource, error) {
+	switch {
+	case strings.HasPrefix(source, "local:///"):
+		return bucketSource(strings.TrimPrefix(source, "local:///"))
+	case strings.HasPrefix(source, "s3://"):
+		sourceBucket, key, _ := strings.Cut(strings.TrimPrefix(source, "s3://"), "/")
+		if sourceBucket != bucket {
+			return ImageSource{}, invalidURL("unknown bucket %q", sourceBucket)
+		}
+		return bucketSource(key)
+	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		// Запрос и фрагмент не входят в путь апстрима, а через них путь ключа кеша выходил бы за пределы proxy/
+		if strings.ContainsAny(source, "?#") {
+			return ImageSource{}, invalidURL("source must not have query or fragment: %q", source)
+		}
+		service, rawPath, err := ServiceFromURL(source)
+		if err != nil {
+			return ImageSource{}, invalidURL("%v", err)
+		}
+		// Путь проверяется и после еще одного раскодирования: апстрим может раскодировать %2e%2e в ..
+		unescaped, err := url.PathUnescape(rawPath)
+		if err != nil || ValidateProxyPath(rawPath) != nil || ValidateProxyPath(unescaped) != nil {
+			return ImageSource{}, invalidURL("invalid upstream path %q", rawPath)
+		}
+		return ImageSource{Service: service, File: rawPath, Proxied: true}, nil
+	}
+
+	return ImageSource{}, invalidURL("unsupported source %q", source)
+}
+
+func bucketSource(key string) (ImageSource, error) {
+	entity, file, ok := strings.Cut(key, "/")
+	if !ok || entity == "" || file == "" {
+		return ImageSource{}, invalidURL("source must be <entity>/<file>, got %q", key)
+	}
+
+	if err := ValidateKey(entity, file); err != nil {
+		return ImageSource{}, err
+	}
+
+	return ImageSource{Entity: entity, File: file}, nil
+}
+
+func invalidURL(format string, args ...any) error {
+	return &image.LimitError{Code: CodeInvalidURL, Message: fmt.Sprintf(format, args...)}
+}
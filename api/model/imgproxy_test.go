@@ -0,0 +1,206 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"resizer/config"
+	"resizer/converter/image"
+)
+
+var testPresets = config.Presets{
+	"thumb": {Width: 200, Height: 100, Fit: "cover", Quality: 70, Format: "webp"},
+}
+
+func TestParseImgproxyPath(t *testing.T) {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte("local:///movie/1.png"))
+
+	tests := []struct {
+		name        string
+		path        string
+		source      string
+		request     ImageRequest
+		signedPath  string
+		onlyPresets bool
+	}{
+		{
+			name:       "plain source with extension",
+			path:       "/sig/rs:fit:300:200/q:75/plain/local:///movie/1.jpg@webp",
+			source:     "local:///movie/1.jpg",
+			request:    ImageRequest{Width: 300, Height: 200, Fit: image.FitContain, Quality: 75, Type: image.WEBP},
+			signedPath: "/rs:fit:300:200/q:75/plain/local:///movie/1.jpg@webp",
+		},
+		{
+			name:       "plain source is unescaped",
+			path:       "/sig/w:100/plain/local:///movie/a%20b.jpg",
+			source:     "local:///movie/a b.jpg",
+			request:    ImageRequest{Width: 100, Quality: imgproxyDefaultQuality, Type: image.JPEG},
+			signedPath: "/w:100/plain/local:///movie/a%20b.jpg",
+		},
+		{
+			name:       "base64 source keeps the source format",
+			path:       "/sig/s:50:60/" + encoded,
+			source:     "local:///movie/1.png",
+			request:    ImageRequest{Width: 50, Height: 60, Quality: imgproxyDefaultQuality, Type: image.PNG},
+			signedPath: "/s:50:60/" + encoded,
+		},
+		{
+			name:       "base64 source with extension and zero quality",
+			path:       "/sig/q:0/" + encoded + ".avif",
+			source:     "local:///movie/1.png",
+			request:    ImageRequest{Quality: imgproxyDefaultQuality, Type: image.AVIF},
+			signedPath: "/q:0/" + encoded + ".avif",
+		},
+		{
+			name:        "preset only",
+			path:        "/sig/pr:thumb/plain/local:///movie/1.jpg",
+			source:      "local:///movie/1.jpg",
+			request:     ImageRequest{Width: 200, Height: 100, Fit: image.FitCover, Quality: 70, Type: image.WEBP},
+			signedPath:  "/pr:thumb/plain/local:///movie/1.jpg",
+			onlyPresets: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := ParseImgproxyPath(tt.path, testPresets)
+			if err != nil {
+				t.Fatalf("ParseImgproxyPath(%q): %v", tt.path, err)
+			}
+
+			if u.Signature != "sig" || u.SignedPath != tt.signedPath || u.Source != tt.source || u.OnlyPresets != tt.onlyPresets {
+				t.Errorf("ParseImgproxyPath(%q) = signature %q, signed path %q, source %q, only presets %v",
+					tt.path, u.Signature, u.SignedPath, u.Source, u.OnlyPresets)
+			}
+
+			got := u.Request
+			if got.Width != tt.request.Width || got.Height != tt.request.Height || got.Fit != tt.request.Fit ||
+				got.Quality != tt.request.Quality || got.Type != tt.request.Type {
+				t.Errorf("ParseImgproxyPath(%q) request = %+v, want %+v", tt.path, got, tt.request)
+			}
+		})
+	}
+}
+
+func TestParseImgproxyPathErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"no signature", "/"},
+		{"no source", "/sig/w:100"},
+		{"empty plain source", "/sig/w:100/plain/"},
+		{"unsupported option", "/sig/blur:5/plain/local:///movie/1.jpg"},
+		{"invalid width", "/sig/w:abc/plain/local:///movie/1.jpg"},
+		{"unknown preset", "/sig/pr:missing/plain/local:///movie/1.jpg"},
+		{"unsupported format", "/sig/plain/local:///movie/1.jpg@bmp"},
+		{"invalid base64", "/sig/w:100/!!!"},
+		{"invalid plain escape", "/sig/plain/local:///movie/%zz.jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseImgproxyPath(tt.path, testPresets); err == nil {
+				t.Errorf("ParseImgproxyPath(%q) succeeded, want error", tt.path)
+			}
+		})
+	}
+}
+
+func TestResolveSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   ImageSource
+	}{
+		{"local original", "local:///movie/1.jpg", ImageSource{Entity: "movie", File: "1.jpg"}},
+		{"s3 original", "s3://images/movie/1.jpg", ImageSource{Entity: "movie", File: "1.jpg"}},
+		{
+			"kinopoisk upstream", "https://avatars.mds.yandex.net/get-kinopoisk-image/1/abc/orig",
+			ImageSource{Service: KinopoiskImages, File: "1/abc/orig", Proxied: true},
+		},
+		{
+			"http upstream", "http://image.tmdb.org/t/p/w500/a.jpg",
+			ImageSource{Service: TmdbImages, File: "w500/a.jpg", Proxied: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveSource(tt.source, "images")
+			if err != nil {
+				t.Fatalf("ResolveSource(%q): %v", tt.source, err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveSource(%q) = %+v, want %+v", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSourceRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"unknown scheme", "ftp://host/movie/1.jpg"},
+		{"other bucket", "s3://other/movie/1.jpg"},
+		{"unknown upstream", "https://evil.org/1.jpg"},
+		{"local without file", "local:///movie"},
+		{"local traversal", "local:///../movie/1.jpg"},
+		{"local nested file", "local:///movie/a/../1.jpg"},
+		{"local reserved proxy", "local:///proxy/kinopoisk-images"},
+		{"local reserved variants", "local:///variants/movie"},
+		{"s3 traversal", "s3://images/movie/.."},
+		{"upstream dot dot", "https://avatars.mds.yandex.net/get-kinopoisk-image/1/../../../posters/1.jpg"},
+		{"upstream dot", "https://avatars.mds.yandex.net/get-kinopoisk-image/./1.jpg"},
+		{"upstream empty segment", "https://avatars.mds.yandex.net/get-kinopoisk-image/1//abc"},
+		{"upstream escaped dot dot", "https://avatars.mds.yandex.net/get-kinopoisk-image/1/%2e%2e/%2e%2e/posters/1.jpg"},
+		{"upstream query", "https://avatars.mds.yandex.net/get-kinopoisk-image/1/abc/orig?x=/../../../../posters/1.jpg"},
+		{"upstream fragment", "https://avatars.mds.yandex.net/get-kinopoisk-image/1/abc/orig#/../../posters/1.jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := ResolveSource(tt.source, "images"); err == nil {
+				t.Errorf("ResolveSource(%q) = %+v, want error", tt.source, got)
+			}
+		})
+	}
+}
+
+// TestImgproxySourceTraversal проверяет весь путь от URL до источника: ни закодированный base64,
+// ни дважды экранированный plain источник не должны выйти за пределы кеша прокси
+func TestImgproxySourceTraversal(t *testing.T) {
+	upstream := "https://avatars.mds.yandex.net/get-kinopoisk-image/1/abc/orig"
+	encode := func(source string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(source))
+	}
+
+	paths := []string{
+		"/sig/w:100/" + encode(upstream+"?x=/../../../../posters/1.jpg") + ".jpg",
+		"/sig/w:100/" + encode("https://avatars.mds.yandex.net/get-kinopoisk-image/../../posters/1.jpg"),
+		"/sig/w:100/plain/https://avatars.mds.yandex.net/get-kinopoisk-image/%252e%252e/%252e%252e/posters/1.jpg",
+		"/sig/w:100/plain/https://avatars.mds.yandex.net/get-kinopoisk-image/1/%2e%2e/%2e%2e/%2e%2e/posters/1.jpg@webp",
+		"/sig/w:100/plain/local:///movie%2f..%2f..%2fposters/1.jpg",
+	}
+
+	for _, rawPath := range paths {
+		u, err := ParseImgproxyPath(rawPath, testPresets)
+		if err != nil {
+			continue
+		}
+
+		source, err := ResolveSource(u.Source, "images")
+		if err == nil {
+			t.Errorf("%q resolved to %+v, want error", rawPath, source)
+			continue
+		}
+
+		var limitErr *image.LimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("%q: error %v is not a LimitError", rawPath, err)
+		}
+	}
+}
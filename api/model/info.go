@@ -0,0 +1,23 @@
+package model
+
+type ImageInfo struct {
+	Key         string `json:"key"`
+	Format      string `json:"format"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Orientation int    `json:"orientation"`
+	ColorSpace  string `json:"color_space"`
+	HasAlpha    bool   `json:"has_alpha"`
+	Size        int64  `json:"size"`
+	Frames      int    `json:"frames"`
+}
+
+type FormatSupport struct {
+	Load bool `json:"load"`
+	Save bool `json:"save"`
+}
+
+type ServiceInfo struct {
+	VipsVersion string                   `json:"vips_version"`
+	Formats     map[string]FormatSupport `json:"formats"`
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"resizer/converter/image"
+)
+
+// MaxIngestItems ограничивает размер одной фоновой задачи загрузки
+const MaxIngestItems = 1000
+
+// IngestItem - загрузка изображения по URL в слот <entity>/<file>
+type IngestItem struct {
+	URL string `json:"url"`
+	UploadRequest
+	Replace bool `json:"replace"`
+}
+
+func (r IngestItem) Validate(limits image.Limits) error {
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &image.LimitError{Code: CodeInvalidURL, Message: fmt.Sprintf("source must be an http(s) URL, got %q", r.URL)}
+	}
+
+	return r.UploadRequest.Validate(limits)
+}
+
+type IngestJobRequest struct {
+	Items []IngestItem `json:"items"`
+}
+
+func (r IngestJobRequest) Validate(limits image.Limits) error {
+	if len(r.Items) == 0 || len(r.Items) > MaxIngestItems {
+		return &image.LimitError{Code: image.CodeInvalidOption, Message: fmt.Sprintf("job must have 1 to %d items, got %d", MaxIngestItems, len(r.Items))}
+	}
+
+	for idx, item := range r.Items {
+		// Номер элемента добавляется в сообщение, код ошибки остается прежним
+		var limitErr *image.LimitError
+		if err := item.Validate(limits); errors.As(err, &limitErr) {
+			return &image.LimitError{Code: limitErr.Code, Message: fmt.Sprintf("item %d: %s", idx, limitErr.Message)}
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+type JobStatus string
+
+const (
+	JobPending JobStatus = "pending"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// JobItem - результат одного элемента фоновой задачи
+type JobItem struct {
+	Key    string     `json:"key"`
+	Status JobStatus  `json:"status"`
+	Error  string     `json:"error,omitempty"`
+	Code   string     `json:"code,omitempty"`
+	Info   *ImageInfo `json:"info,omitempty"`
+}
+
+// Job - фоновая задача. Статус done означает, что все элементы обработаны, failed - что хотя бы один завершился ошибкой
+type Job struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	Status     JobStatus  `json:"status"`
+	Total      int        `json:"total"`
+	Completed  int        `json:"completed"`
+	Failed     int        `json:"failed"`
+	Items      []JobItem  `json:"items"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+
+	"resizer/converter/image"
+	"resizer/converter/image/format"
+)
+
+// ParseOverrides reads encoder options from query parameters. Absent parameters keep the server defaults.
+func ParseOverrides(query map[string]string) (image.Overrides, error) {
+	var (
+		o   image.Overrides
+		err error
+	)
+
+	boolParam := func(name string) *bool {
+		raw, ok := query[name]
+		if !ok || err != nil {
+			return nil
+		}
+		v, parseErr := strconv.ParseBool(raw)
+		if parseErr != nil {
+			err = invalidOption(name, raw)
+			return nil
+		}
+		return &v
+	}
+	intParam := func(name string) *int {
+		raw, ok := query[name]
+		if !ok || err != nil {
+			return nil
+		}
+		v, parseErr := strconv.Atoi(raw)
+		if parseErr != nil {
+			err = invalidOption(name, raw)
+			return nil
+		}
+		return &v
+	}
+
+	o.Interlace = boolParam("progressive")
+	o.Trellis = boolParam("trellis")
+	o.Lossless = boolParam("lossless")
+	o.NearLossless = boolParam("near_lossless")
+	o.Palette = boolParam("palette")
+	o.Effort = intParam("effort")
+	o.Compression = intParam("compression")
+
+	if raw, ok := query["subsampling"]; ok && err == nil {
+		s := format.Subsampling(raw)
+		switch s {
+		case format.SubsamplingAuto, format.Subsampling420, format.Subsampling444:
+			o.Subsampling = &s
+		default:
+			err = invalidOption("subsampling", raw)
+		}
+	}
+
+	return o, err
+}
+
+func invalidOption(name, value string) error {
+	return &image.LimitError{Code: image.CodeInvalidOption, Message: fmt.Sprintf("invalid %s value %q", name, value)}
+}
@@ -0,0 +1,8 @@
+package model
+
+type Placeholder struct {
+	BlurHash string `json:"blurhash"`
+	LQIP     string `json:"lqip"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Preconditions holds the client's conditional request headers.
+type Preconditions struct {
+	IfNoneMatch     string
+	IfModifiedSince time.Time
+}
+
+// NotModified reports whether a resource with the given validators can be answered with 304.
+// If-Modified-Since is ignored when If-None-Match is present (RFC 9110, 13.1.3).
+func (p Preconditions) NotModified(etag string, lastModified time.Time) bool {
+	if p.IfNoneMatch != "" {
+		if etag == "" {
+			return false
+		}
+
+		for _, candidate := range strings.Split(p.IfNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if p.IfModifiedSince.IsZero() || lastModified.IsZero() {
+		return false
+	}
+
+	return !lastModified.Truncate(time.Second).After(p.IfModifiedSince)
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"resizer/config"
+)
+
+var ErrUnknownPreset = errors.New("unknown preset")
+
+// PresetRequest собирает запрос на преобразование из пресета, заданного в конфигурации
+func PresetRequest(entity, file string, presets config.Presets, name string) (ImageRequest, error) {
+	preset, ok := presets[name]
+	if !ok {
+		return ImageRequest{}, fmt.Errorf("%w: %s", ErrUnknownPreset, name)
+	}
+
+	request := ImageRequest{
+		Entity:  entity,
+		File:    file,
+		Width:   preset.Width,
+		Height:  preset.Height,
+		Quality: preset.Quality,
+	}
+
+	if err := request.Type.UnmarshalText([]byte(preset.Format)); err != nil {
+		return ImageRequest{}, fmt.Errorf("preset %s: format %q: %w", name, preset.Format, err)
+	}
+
+	if preset.Fit != "" {
+		if err := request.Fit.UnmarshalText([]byte(preset.Fit)); err != nil {
+			return ImageRequest{}, fmt.Errorf("preset %s: fit %q: %w", name, preset.Fit, err)
+		}
+	}
+
+	options, err := ParseOverrides(preset.Options)
+	if err != nil {
+		return ImageRequest{}, fmt.Errorf("preset %s: %w", name, err)
+	}
+	request.Options = options
+
+	return request, nil
+}
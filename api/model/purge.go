@@ -0,0 +1,50 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"resizer/converter/image"
+)
+
+// purgeablePrefixes - части бакета, которые являются кешем и могут быть удалены целиком
+var purgeablePrefixes = []string{"proxy/", "variants/"}
+
+type PurgeResponse struct {
+	Prefixes []string `json:"prefixes"`
+	Purged   int      `json:"purged"`
+}
+
+// ValidateProxyPath проверяет путь изображения апстрима, из которого строятся ключи кеша:
+// пустые сегменты, . и .. позволили бы ключу выйти за пределы proxy/<service>/
+func ValidateProxyPath(rawPath string) error {
+	for _, segment := range strings.Split(rawPath, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return &image.LimitError{Code: CodeInvalidKey, Message: fmt.Sprintf("invalid path %q", rawPath)}
+		}
+	}
+
+	return nil
+}
+
+// ValidatePurgePrefix разрешает удалять по префиксу только кеш прокси и варианты, но не оригиналы
+func ValidatePurgePrefix(prefix string) error {
+	if strings.Contains(prefix, "..") {
+		return &image.LimitError{Code: CodeInvalidKey, Message: fmt.Sprintf("invalid prefix %q", prefix)}
+	}
+
+	// Завершающий слеш допустим, а пустые сегменты и . внутри префикса не совпадут ни с одним ключом
+	for _, segment := range strings.Split(strings.TrimSuffix(prefix, "/"), "/") {
+		if segment == "" || segment == "." {
+			return &image.LimitError{Code: CodeInvalidKey, Message: fmt.Sprintf("invalid prefix %q", prefix)}
+		}
+	}
+
+	for _, allowed := range purgeablePrefixes {
+		if strings.HasPrefix(prefix, allowed) {
+			return nil
+		}
+	}
+
+	return &image.LimitError{Code: CodeInvalidKey, Message: fmt.Sprintf("prefix must start with one of %s, got %q", strings.Join(purgeablePrefixes, ", "), prefix)}
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"resizer/converter/image"
+)
+
+func TestValidateProxyPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		valid bool
+	}{
+		{"single file", "abc.jpg", true},
+		{"nested path", "1/abc/orig", true},
+		{"dots inside names", "w500/a..b.jpg", true},
+		{"empty", "", false},
+		{"dot", ".", false},
+		{"dot dot", "..", false},
+		{"leading dot dot", "../posters/1.jpg", false},
+		{"inner dot dot", "a/../../posters/x.jpg", false},
+		{"trailing dot dot", "1/abc/..", false},
+		{"inner dot", "1/./abc", false},
+		{"leading slash", "/1/abc", false},
+		{"trailing slash", "1/abc/", false},
+		{"empty segment", "1//abc", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertKeyError(t, ValidateProxyPath(tt.path), tt.valid, "ValidateProxyPath(%q)", tt.path)
+		})
+	}
+}
+
+func TestValidatePurgePrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		valid  bool
+	}{
+		{"proxy service", "proxy/kinopoisk-images/", true},
+		{"proxy path prefix", "proxy/tmdb-images/w500/a", true},
+		{"variants entity", "variants/posters/", true},
+		{"variants of proxy", "variants/proxy/kinopoisk-images/", true},
+		{"bare proxy", "proxy/", true},
+		{"bare variants", "variants/", true},
+		{"proxy without slash", "proxy", false},
+		{"lookalike prefix", "proxyx/", false},
+		{"original entity", "posters/", false},
+		{"empty", "", false},
+		{"root", "/", false},
+		{"leading slash", "/proxy/", false},
+		{"dot dot to originals", "proxy/../posters/", false},
+		{"dot dot in variants", "variants/..", false},
+		{"dot segment", "proxy/./kinopoisk-images/", false},
+		{"empty segment", "proxy//kinopoisk-images/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertKeyError(t, ValidatePurgePrefix(tt.prefix), tt.valid, "ValidatePurgePrefix(%q)", tt.prefix)
+		})
+	}
+}
+
+func assertKeyError(t *testing.T, err error, valid bool, format string, args ...any) {
+	t.Helper()
+
+	if valid {
+		if err != nil {
+			t.Errorf(format+" = %v, want nil", append(args, err)...)
+		}
+		return
+	}
+
+	var limitErr *image.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Code != CodeInvalidKey {
+		t.Errorf(format+" = %v, want %s", append(args, err, CodeInvalidKey)...)
+	}
+}
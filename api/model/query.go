@@ -0,0 +1,73 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"resizer/converter/image"
+)
+
+const defaultQueryQuality = 80
+
+// queryParams - все параметры, которые принимает /img, включая настройки кодировщика
+var queryParams = map[string]bool{
+	"w": true, "h": true, "q": true, "fmt": true, "fit": true, "dpr": true, "first_frame": true,
+	"progressive": true, "trellis": true, "subsampling": true, "lossless": true,
+	"near_lossless": true, "effort": true, "palette": true, "compression": true,
+}
+
+// ImageQuery - параметры преобразования из строки запроса /img/:entity/*file
+type ImageQuery struct {
+	Width      int     `query:"w"`
+	Height     int     `query:"h"`
+	Quality    float32 `query:"q"`
+	Format     string  `query:"fmt"`
+	Fit        string  `query:"fit"`
+	DPR        float32 `query:"dpr"`
+	FirstFrame bool    `query:"first_frame"`
+}
+
+// CheckQueryKeys отклоняет неизвестные параметры, чтобы опечатка не превращалась в молчаливое значение по умолчанию
+func CheckQueryKeys(query map[string]string) error {
+	for key := range query {
+		if !queryParams[key] {
+			return &image.LimitError{Code: image.CodeInvalidOption, Message: fmt.Sprintf("unknown parameter %q", key)}
+		}
+	}
+
+	return nil
+}
+
+// Request переводит параметры запроса в ImageRequest, подставляя значения по умолчанию:
+// исходный размер, качество 80, WebP, обрезка по центру и плотность 1x
+func (q ImageQuery) Request(entity, file string) (ImageRequest, error) {
+	r := ImageRequest{
+		Entity:     entity,
+		File:       file,
+		Width:      q.Width,
+		Height:     q.Height,
+		Quality:    q.Quality,
+		Type:       image.WEBP,
+		FirstFrame: q.FirstFrame,
+	}
+
+	if r.Quality == 0 {
+		r.Quality = defaultQueryQuality
+	}
+
+	if q.Format != "" {
+		if err := unmarshalImgproxyFormat(&r.Type, strings.ToLower(q.Format)); err != nil {
+			return ImageRequest{}, &image.LimitError{Code: image.CodeInvalidOption, Message: fmt.Sprintf("unknown format %q", q.Format)}
+		}
+	}
+
+	if q.Fit != "" {
+		if err := r.Fit.UnmarshalText([]byte(q.Fit)); err != nil {
+			return ImageRequest{}, &image.LimitError{Code: image.CodeInvalidOption, Message: fmt.Sprintf("unknown fit %q", q.Fit)}
+		}
+	}
+
+	r.DPR = q.DPR
+
+	return r, nil
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Range is a single byte range from the Range request header.
+// Multi-range requests are not supported and are served in full.
+type Range struct {
+	Start  int64
+	End    int64 // -1 for open-ended ranges like "bytes=100-"
+	Suffix int64 // >0 for suffix ranges like "bytes=-500"
+
+	set bool
+}
+
+// ParseRange parses a single "bytes=" range. Anything else yields an unset Range.
+func ParseRange(header string) Range {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return Range{}
+	}
+
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return Range{}
+	}
+
+	if first == "" {
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix <= 0 {
+			return Range{}
+		}
+		return Range{Suffix: suffix, set: true}
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return Range{}
+	}
+
+	if last == "" {
+		return Range{Start: start, End: -1, set: true}
+	}
+
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return Range{}
+	}
+
+	return Range{Start: start, End: end, set: true}
+}
+
+func (r Range) IsSet() bool {
+	return r.set
+}
+
+// String formats the range for the S3 GetObject Range parameter.
+func (r Range) String() string {
+	switch {
+	case r.Suffix > 0:
+		return fmt.Sprintf("bytes=-%d", r.Suffix)
+	case r.End < 0:
+		return fmt.Sprintf("bytes=%d-", r.Start)
+	default:
+		return fmt.Sprintf("bytes=%d-%d", r.Start, r.End)
+	}
+}
+
+// Bounds resolves the range against the full size. ok is false when the range is not satisfiable.
+func (r Range) Bounds(size int64) (start, end int64, ok bool) {
+	if size <= 0 {
+		return 0, 0, false
+	}
+
+	if r.Suffix > 0 {
+		return max(size-r.Suffix, 0), size - 1, true
+	}
+
+	if r.Start >= size {
+		return 0, 0, false
+	}
+
+	end = r.End
+	if end < 0 || end >= size {
+		end = size - 1
+	}
+
+	return r.Start, end, true
+}
@@ -1,9 +1,13 @@
 package model
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 )
 
+var ErrUnknownService = errors.New("unknown service type")
+
 type ServiceName struct {
 	s string
 }
@@ -31,7 +35,7 @@ func MakeFromString(s string) (ServiceName, error) {
 		return KinopoiskStImages, nil
 	}
 
-	return ServiceName{}, fmt.Errorf("unknown type: %s", s)
+	return ServiceName{}, fmt.Errorf("%w: %s", ErrUnknownService, s)
 }
 
 func (t ServiceName) GetReplaceSize() string {
@@ -67,3 +71,31 @@ func (t ServiceName) ToProxyURL(tmdbProxyURL string) string {
 func (t ServiceName) IsKinopoiskImages() bool {
 	return t == KinopoiskImages || t == KinopoiskOttImages || t == KinopoiskStImages
 }
+
+// upstreamBases сопоставляет адреса апстримов с сервисами прокси
+var upstreamBases = []struct {
+	base    string
+	service ServiceName
+}{
+	{"https://avatars.mds.yandex.net/get-kinopoisk-image/", KinopoiskImages},
+	{"https://avatars.mds.yandex.net/get-ott/", KinopoiskOttImages},
+	{"https://st.kp.yandex.net/images/", KinopoiskStImages},
+	{"https://image.tmdb.org/t/p/", TmdbImages},
+	{"https://www.themoviedb.org/t/p/", TmdbImages},
+}
+
+// ServiceFromURL находит сервис прокси по полному адресу изображения и возвращает путь внутри сервиса
+func ServiceFromURL(rawURL string) (ServiceName, string, error) {
+	normalized := rawURL
+	if strings.HasPrefix(normalized, "http://") {
+		normalized = "https://" + strings.TrimPrefix(normalized, "http://")
+	}
+
+	for _, upstream := range upstreamBases {
+		if rawPath, ok := strings.CutPrefix(normalized, upstream.base); ok && rawPath != "" {
+			return upstream.service, rawPath, nil
+		}
+	}
+
+	return ServiceName{}, "", fmt.Errorf("unknown upstream: %s", rawURL)
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+type SrcsetEntry struct {
+	URL     string  `json:"url"`
+	Width   int     `json:"width,omitempty"`
+	Density float32 `json:"density,omitempty"`
+}
+
+// Srcset - готовые атрибуты для <img>: src указывает на самый маленький вариант
+type Srcset struct {
+	Src     string        `json:"src"`
+	Srcset  string        `json:"srcset"`
+	Sizes   string        `json:"sizes,omitempty"`
+	Sources []SrcsetEntry `json:"sources"`
+}
+
+func NewSrcset(entries []SrcsetEntry, sizes string) Srcset {
+	candidates := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Width > 0 {
+			candidates = append(candidates, fmt.Sprintf("%s %dw", entry.URL, entry.Width))
+		} else {
+			candidates = append(candidates, fmt.Sprintf("%s %gx", entry.URL, entry.Density))
+		}
+	}
+
+	srcset := Srcset{Srcset: strings.Join(candidates, ", "), Sources: entries}
+	if len(entries) > 0 {
+		srcset.Src = entries[0].URL
+	}
+	// sizes имеет смысл только для дескрипторов ширины
+	if len(entries) > 0 && entries[0].Width > 0 {
+		srcset.Sizes = sizes
+	}
+
+	return srcset
+}
+
+// HTML возвращает фрагмент <img> с экранированными атрибутами
+func (s Srcset) HTML(alt string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<img src="%s" srcset="%s"`, html.EscapeString(s.Src), html.EscapeString(s.Srcset))
+	if s.Sizes != "" {
+		fmt.Fprintf(&b, ` sizes="%s"`, html.EscapeString(s.Sizes))
+	}
+	fmt.Fprintf(&b, ` alt="%s">`, html.EscapeString(alt))
+
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"resizer/converter/image"
+)
+
+const (
+	CodeNotImage   = "not_an_image"
+	CodeInvalidKey = "invalid_key"
+)
+
+// reservedEntities - префиксы бакета, занятые кешем прокси и вариантами
+var reservedEntities = []string{"proxy", "variants"}
+
+// UploadRequest - параметры загрузки оригинала. Нормализация выполняется,
+// если задан максимальный размер или удаление метаданных
+type UploadRequest struct {
+	Entity    string `json:"entity" query:"-"`
+	File      string `json:"file" query:"-"`
+	MaxWidth  int    `json:"max_width" query:"max_width"`
+	MaxHeight int    `json:"max_height" query:"max_height"`
+	Strip     bool   `json:"strip" query:"strip"`
+}
+
+// Key возвращает ключ оригинала в бакете
+func (r UploadRequest) Key() string {
+	return fmt.Sprintf("%s/%s", r.Entity, r.File)
+}
+
+// Normalize сообщает, нужно ли перекодировать оригинал перед сохранением
+func (r UploadRequest) Normalize() bool {
+	return r.MaxWidth > 0 || r.MaxHeight > 0 || r.Strip
+}
+
+func (r UploadRequest) Validate(limits image.Limits) error {
+	if err := ValidateKey(r.Entity, r.File); err != nil {
+		return err
+	}
+
+	if err := limits.CheckWidth(r.MaxWidth); err != nil {
+		return err
+	}
+
+	return limits.CheckHeight(r.MaxHeight)
+}
+
+// ValidateKey не дает записать или удалить объекты вне оригиналов сущностей
+func ValidateKey(entity, file string) error {
+	if slices.Contains(reservedEntities, entity) {
+		return &image.LimitError{Code: CodeInvalidKey, Message: fmt.Sprintf("entity %q is reserved", entity)}
+	}
+
+	for _, part := range []string{entity, file} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, "/\\") {
+			return &image.LimitError{Code: CodeInvalidKey, Message: fmt.Sprintf("invalid key part %q", part)}
+		}
+	}
+
+	return nil
+}
+
+type DeleteResponse struct {
+	Key            string `json:"key"`
+	PurgedVariants int    `json:"purged_variants"`
+}
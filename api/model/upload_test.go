@@ -0,0 +1,51 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"resizer/converter/image"
+)
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		entity string
+		file   string
+		valid  bool
+	}{
+		{"original", "movie", "1.jpg", true},
+		{"dots inside names", "movie.v2", "poster..final.jpg", true},
+		{"dot file", "movie", ".hidden", true},
+		{"empty entity", "", "1.jpg", false},
+		{"empty file", "movie", "", false},
+		{"dot entity", ".", "1.jpg", false},
+		{"dot file name", "movie", ".", false},
+		{"dot dot entity", "..", "1.jpg", false},
+		{"dot dot file", "movie", "..", false},
+		{"slash in entity", "movie/sub", "1.jpg", false},
+		{"slash in file", "movie", "../1.jpg", false},
+		{"leading slash in file", "movie", "/1.jpg", false},
+		{"backslash in entity", `movie\..`, "1.jpg", false},
+		{"backslash in file", "movie", `..\1.jpg`, false},
+		{"reserved proxy", "proxy", "1.jpg", false},
+		{"reserved variants", "variants", "1.jpg", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateKey(tt.entity, tt.file)
+			if tt.valid {
+				if err != nil {
+					t.Errorf("ValidateKey(%q, %q) = %v, want nil", tt.entity, tt.file, err)
+				}
+				return
+			}
+
+			var limitErr *image.LimitError
+			if !errors.As(err, &limitErr) || limitErr.Code != CodeInvalidKey {
+				t.Errorf("ValidateKey(%q, %q) = %v, want %s", tt.entity, tt.file, err, CodeInvalidKey)
+			}
+		})
+	}
+}
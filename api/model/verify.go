@@ -0,0 +1,68 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"resizer/converter/image"
+)
+
+type VerifyAction string
+
+const (
+	VerifyDryRun     VerifyAction = "dry-run"
+	VerifyDelete     VerifyAction = "delete"
+	VerifyQuarantine VerifyAction = "quarantine"
+)
+
+// VerifyOptions - параметры проверки объектов с префиксом. Rate ограничивает число проверяемых объектов в секунду
+type VerifyOptions struct {
+	Prefix           string
+	Action           VerifyAction
+	QuarantinePrefix string
+	Rate             int
+}
+
+// Validate разрешает менять объекты только в кеше, перенос в карантин - только за пределы проверяемого префикса
+func (o VerifyOptions) Validate() error {
+	switch o.Action {
+	case VerifyDryRun:
+		return nil
+	case VerifyDelete:
+		return ValidatePurgePrefix(o.Prefix)
+	case VerifyQuarantine:
+		if err := ValidatePurgePrefix(o.Prefix); err != nil {
+			return err
+		}
+		if o.QuarantinePrefix == "" || ValidatePurgePrefix(o.QuarantinePrefix) == nil {
+			return &image.LimitError{Code: CodeInvalidKey, Message: fmt.Sprintf("quarantine prefix %q must be outside the cache", o.QuarantinePrefix)}
+		}
+		return nil
+	}
+
+	return &image.LimitError{Code: image.CodeInvalidOption, Message: fmt.Sprintf("unknown verify action %q", o.Action)}
+}
+
+// BadObject - объект кеша, который не является целым изображением
+type BadObject struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+	Size   int64  `json:"size"`
+}
+
+type VerifyReport struct {
+	Prefix      string       `json:"prefix"`
+	Action      VerifyAction `json:"action"`
+	Scanned     int          `json:"scanned"`
+	Bad         []BadObject  `json:"bad"`
+	Deleted     int          `json:"deleted"`
+	Quarantined int          `json:"quarantined"`
+	StartedAt   time.Time    `json:"started_at"`
+	FinishedAt  time.Time    `json:"finished_at"`
+}
+
+type RetryReport struct {
+	Total     int `json:"total"`
+	Recovered int `json:"recovered"`
+	Failed    int `json:"failed"`
+}
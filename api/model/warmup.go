@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"resizer/converter/image"
+)
+
+const (
+	MaxWarmupSources  = 1000
+	MaxWarmupVariants = 20
+
+	warmupDefaultQuality = 80
+)
+
+// WarmupRequest - источники для прогрева и варианты, которые строятся для каждого из них.
+// Proxy - пути вида <service_type>/<path>, Images - оригиналы <entity>/<file>
+type WarmupRequest struct {
+	Proxy   []string `json:"proxy"`
+	Images  []string `json:"images"`
+	Widths  []int    `json:"widths"`
+	Formats []string `json:"formats"`
+	Quality float32  `json:"quality"`
+}
+
+// Sources разбирает пути источников
+func (r WarmupRequest) Sources() ([]ImageSource, error) {
+	total := len(r.Proxy) + len(r.Images)
+	if total == 0 || total > MaxWarmupSources {
+		return nil, &image.LimitError{Code: image.CodeInvalidOption, Message: fmt.Sprintf("warmup must have 1 to %d sources, got %d", MaxWarmupSources, total)}
+	}
+
+	sources := make([]ImageSource, 0, total)
+	for _, proxyPath := range r.Proxy {
+		rawService, rawPath, _ := strings.Cut(strings.TrimPrefix(proxyPath, "/"), "/")
+		service, err := MakeFromString(rawService)
+		if err != nil || rawPath == "" {
+			return nil, &image.LimitError{Code: CodeInvalidKey, Message: fmt.Sprintf("proxy path must be <service_type>/<path>, got %q", proxyPath)}
+		}
+		if err = ValidateProxyPath(rawPath); err != nil {
+			return nil, err
+		}
+
+		// Источник записывается в кеш прокси, поэтому его ключ не должен выходить за пределы proxy/<service>/
+		source := ImageSource{Service: service, File: rawPath, Proxied: true}
+		if !strings.HasPrefix(source.Key(), path.Join("proxy", service.String())+"/") {
+			return nil, &image.LimitError{Code: CodeInvalidKey, Message: fmt.Sprintf("proxy path %q escapes the proxy cache", proxyPath)}
+		}
+		sources = append(sources, source)
+	}
+
+	for _, key := range r.Images {
+		entity, file, _ := strings.Cut(strings.TrimPrefix(key, "/"), "/")
+		if err := ValidateKey(entity, file); err != nil {
+			return nil, err
+		}
+		sources = append(sources, ImageSource{Entity: entity, File: file})
+	}
+
+	return sources, nil
+}
+
+// Variants возвращает проверенные параметры вариантов: каждая ширина в каждом формате.
+// Без форматов строится WebP, без ширин источники только загружаются в кеш
+func (r WarmupRequest) Variants(limits image.Limits) ([]ImageRequest, error) {
+	formats := r.Formats
+	if len(formats) == 0 {
+		formats = []string{image.WEBP.String()}
+	}
+
+	quality := r.Quality
+	if quality == 0 {
+		quality = warmupDefaultQuality
+	}
+
+	if len(r.Widths)*len(formats) > MaxWarmupVariants {
+		return nil, &image.LimitError{Code: image.CodeInvalidOption, Message: fmt.Sprintf("at most %d variants per source are allowed", MaxWarmupVariants)}
+	}
+
+	variants := make([]ImageRequest, 0, len(r.Widths)*len(formats))
+	for _, rawFormat := range formats {
+		var t image.Type
+		if err := t.UnmarshalText([]byte(rawFormat)); err != nil {
+			return nil, &image.LimitError{Code: image.CodeUnsupported, Message: fmt.Sprintf("unknown format %q", rawFormat)}
+		}
+
+		for _, width := range r.Widths {
+			params := ImageRequest{Width: width, Quality: quality, Type: t}
+			if err := params.Validate(limits); err != nil {
+				return nil, err
+			}
+			variants = append(variants, params)
+		}
+	}
+
+	return variants, nil
+}
+
+// Key возвращает ключ источника в бакете
+func (s ImageSource) Key() string {
+	if s.Proxied {
+		return path.Join("proxy", s.Service.String(), s.File)
+	}
+
+	return fmt.Sprintf("%s/%s", s.Entity, s.File)
+}
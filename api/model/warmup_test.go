@@ -0,0 +1,50 @@
+package model
+
+import "testing"
+
+func TestWarmupRequestSources(t *testing.T) {
+	request := WarmupRequest{
+		Proxy:  []string{"kinopoisk-images/1/abc/orig", "/tmdb-images/w500/a.jpg"},
+		Images: []string{"movie/1.jpg"},
+	}
+
+	sources, err := request.Sources()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"proxy/kinopoisk-images/1/abc/orig", "proxy/tmdb-images/w500/a.jpg", "movie/1.jpg"}
+	if len(sources) != len(want) {
+		t.Fatalf("Sources() returned %d sources, want %d", len(sources), len(want))
+	}
+	for idx, source := range sources {
+		if source.Key() != want[idx] {
+			t.Errorf("source %d key = %q, want %q", idx, source.Key(), want[idx])
+		}
+	}
+}
+
+func TestWarmupRequestSourcesRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		request WarmupRequest
+	}{
+		{"no sources", WarmupRequest{}},
+		{"unknown service", WarmupRequest{Proxy: []string{"unknown/1.jpg"}}},
+		{"service without path", WarmupRequest{Proxy: []string{"kinopoisk-images"}}},
+		{"proxy traversal", WarmupRequest{Proxy: []string{"kinopoisk-images/a/../../posters/x.jpg"}}},
+		{"proxy dot segment", WarmupRequest{Proxy: []string{"kinopoisk-images/./x.jpg"}}},
+		{"proxy empty segment", WarmupRequest{Proxy: []string{"kinopoisk-images/a//x.jpg"}}},
+		{"proxy trailing dot dot", WarmupRequest{Proxy: []string{"kinopoisk-images/.."}}},
+		{"image traversal", WarmupRequest{Images: []string{"movie/../1.jpg"}}},
+		{"image reserved entity", WarmupRequest{Images: []string{"proxy/1.jpg"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if sources, err := tt.request.Sources(); err == nil {
+				t.Errorf("Sources() = %+v, want error", sources)
+			}
+		})
+	}
+}
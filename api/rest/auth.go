@@ -0,0 +1,37 @@
+package rest
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	errUnauthorized  = errors.New("missing or invalid bearer token")
+	errAdminDisabled = errors.New("ADMIN_TOKEN is not configured")
+)
+
+// authorize пропускает запрос только с токеном ADMIN_TOKEN в заголовке Authorization: Bearer
+func (i *ImageController) authorize(c *fiber.Ctx) error {
+	if err := i.checkToken(c); err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	return c.Next()
+}
+
+// checkToken проверяет токен ADMIN_TOKEN там, где авторизация нужна только части запросов маршрута
+func (i *ImageController) checkToken(c *fiber.Ctx) error {
+	if i.cfg.AdminToken == "" {
+		return errAdminDisabled
+	}
+
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(i.cfg.AdminToken)) != 1 {
+		return errUnauthorized
+	}
+
+	return nil
+}
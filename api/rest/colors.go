@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"resizer/api/model"
+	"resizer/shared/log"
+)
+
+// OriginalColors image colors
+//
+//	@Summary		Get original image colors
+//	@Description	Returns the dominant color and a median-cut palette of a downscaled original as hex and RGB.
+//	@Tags			colors
+//	@Produce		json
+//	@Param			entity	path		string	true	"Entity"
+//	@Param			file	path		string	true	"File name"
+//	@Success		200		{object}	model.Colors
+//	@Router			/colors/images/{entity}/{file} [get]
+func (i *ImageController) OriginalColors(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Second*30)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	colors, err := i.service.OriginalColors(ctx, c.Params("entity"), c.Params("file"))
+	if err != nil {
+		logger.Error("Error extracting colors", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	c.Set("Cache-Control", "max-age=604800")
+	return c.JSON(colors)
+}
+
+// ProxyColors image colors
+//
+//	@Summary		Get proxied image colors
+//	@Description	Returns the dominant color and a median-cut palette of a downscaled proxied image as hex and RGB.
+//	@Tags			colors
+//	@Produce		json
+//	@Param			service_type	path		string	true	"Service Type"
+//	@Param			path			path		string	true	"Path"
+//	@Success		200				{object}	model.Colors
+//	@Router			/colors/{service_type}/{path} [get]
+func (i *ImageController) ProxyColors(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute*5)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	serviceType, err := model.MakeFromString(c.Params("service_type"))
+	if err != nil {
+		logger.Error("invalid service_type", zap.Error(err))
+		return err
+	}
+
+	colors, err := i.service.ProxyColors(ctx, serviceType, c.Params("*"))
+	if err != nil {
+		logger.Error("Error extracting colors", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	c.Set("Cache-Control", "max-age=604800")
+	return c.JSON(colors)
+}
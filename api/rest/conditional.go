@@ -0,0 +1,33 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"resizer/api/model"
+)
+
+// preconditions читает условные заголовки запроса
+func preconditions(c *fiber.Ctx) model.Preconditions {
+	cond := model.Preconditions{IfNoneMatch: c.Get(fiber.HeaderIfNoneMatch)}
+
+	if since := c.Get(fiber.HeaderIfModifiedSince); since != "" {
+		if t, err := http.ParseTime(since); err == nil {
+			cond.IfModifiedSince = t
+		}
+	}
+
+	return cond
+}
+
+// setValidators выставляет ETag и Last-Modified ответа
+func setValidators(c *fiber.Ctx, etag string, lastModified time.Time) {
+	if etag != "" {
+		c.Set(fiber.HeaderETag, etag)
+	}
+
+	if !lastModified.IsZero() {
+		c.Set(fiber.HeaderLastModified, lastModified.UTC().Format(http.TimeFormat))
+	}
+}
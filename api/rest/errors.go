@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"resizer/api/model"
+	"resizer/converter/image"
+	"resizer/service"
+)
+
+var errPresetsOnly = errors.New("only presets are allowed")
+
+// errorResponse переводит известные ошибки сервиса в HTTP-ответы, остальные отдает обработчику Fiber
+func (i *ImageController) errorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, image.ErrQueueFull) || errors.Is(err, image.ErrQueueTimeout) {
+		retryAfter := int(math.Ceil(i.cfg.ProcessQueueTimeout.Seconds()))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(retryAfter, 1)))
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  "overloaded",
+		})
+	}
+
+	if errors.Is(err, errPresetsOnly) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  "presets_only",
+		})
+	}
+
+	if errors.Is(err, errUnauthorized) {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  "unauthorized",
+		})
+	}
+
+	if errors.Is(err, errAdminDisabled) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  "admin_disabled",
+		})
+	}
+
+	if errors.Is(err, service.ErrAlreadyExists) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  "already_exists",
+		})
+	}
+
+	if errors.Is(err, service.ErrBlockedDestination) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  service.CodeBlockedDestination,
+		})
+	}
+
+	if errors.Is(err, service.ErrUpstream) {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  "upstream_error",
+		})
+	}
+
+	if errors.Is(err, errInvalidSignature) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  "invalid_signature",
+		})
+	}
+
+	if errors.Is(err, service.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  "not_found",
+		})
+	}
+
+	if errors.Is(err, model.ErrUnknownService) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  "unknown_service",
+		})
+	}
+
+	if errors.Is(err, model.ErrUnknownPreset) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  "unknown_preset",
+		})
+	}
+
+	if errors.Is(err, service.ErrRangeNotSatisfiable) {
+		var rangeErr *service.RangeNotSatisfiableError
+		if errors.As(err, &rangeErr) && rangeErr.ContentRange != "" {
+			c.Set(fiber.HeaderContentRange, rangeErr.ContentRange)
+		}
+		return c.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+	}
+
+	var limitErr *image.LimitError
+	if errors.As(err, &limitErr) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": limitErr.Message,
+			"code":  limitErr.Code,
+		})
+	}
+
+	return err
+}
@@ -0,0 +1,231 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"resizer/api/model"
+	"resizer/shared/log"
+)
+
+// ProcessHead image metadata
+//
+//	@Summary		Get processed image metadata
+//	@Description	Returns headers of the processed image. Answered from object metadata without transforming; Content-Length and dimensions are sent only for a cached variant.
+//	@Tags			image
+//	@Param			entity	path	string	true	"Entity"
+//	@Param			file	path	string	true	"File name"
+//	@Param			width	path	int		true	"Width"
+//	@Param			quality	path	int		true	"Quality"
+//	@Param			type	path	string	true	"Image type"
+//	@Success		200		"Headers only"
+//	@Success		304		"Image not modified"
+//	@Router			/images/{entity}/{file}/{width}/{quality}/{type} [head]
+func (i *ImageController) ProcessHead(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Second*10)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	if i.cfg.PresetsOnly {
+		return i.errorResponse(c, errPresetsOnly)
+	}
+
+	params := &model.ImageRequest{}
+	if err := c.ParamsParser(params); err != nil {
+		logger.Error("Error parsing params", zap.Error(err))
+		return err
+	}
+	params.FirstFrame = c.QueryBool("first_frame")
+	params.DPR = float32(c.QueryFloat("dpr"))
+	overrides, err := model.ParseOverrides(c.Queries())
+	if err != nil {
+		return i.errorResponse(c, err)
+	}
+	params.Options = overrides
+
+	if err := params.Validate(i.service.Limits()); err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	image, err := i.service.HeadProcess(ctx, *params, preconditions(c))
+	if err != nil {
+		logger.Error("Error getting image metadata", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	return sendHead(c, image)
+}
+
+// PresetHead image metadata
+//
+//	@Summary		Get preset image metadata
+//	@Description	Returns headers of the image processed with a named preset. Answered from object metadata without transforming; Content-Length and dimensions are sent only for a cached variant.
+//	@Tags			image
+//	@Param			entity	path	string	true	"Entity"
+//	@Param			file	path	string	true	"File name"
+//	@Param			preset	path	string	true	"Preset name"
+//	@Success		200		"Headers only"
+//	@Success		304		"Image not modified"
+//	@Router			/images/{entity}/{file}/p/{preset} [head]
+func (i *ImageController) PresetHead(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Second*10)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	params, err := model.PresetRequest(c.Params("entity"), c.Params("file"), i.cfg.Presets, c.Params("preset"))
+	if err != nil {
+		return i.errorResponse(c, err)
+	}
+	params.DPR = float32(c.QueryFloat("dpr"))
+
+	if err = params.Validate(i.service.Limits()); err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	image, err := i.service.HeadProcess(ctx, params, preconditions(c))
+	if err != nil {
+		logger.Error("Error getting image metadata", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	return sendHead(c, image)
+}
+
+// QueryHead image metadata
+//
+//	@Summary		Get query-processed image metadata
+//	@Description	Returns headers of the image processed with query parameters. Answered from object metadata without transforming; Content-Length and dimensions are sent only for a cached variant.
+//	@Tags			image
+//	@Param			entity	path	string	true	"Entity"
+//	@Param			file	path	string	true	"File path"
+//	@Success		200		"Headers only"
+//	@Success		304		"Image not modified"
+//	@Router			/img/{entity}/{file} [head]
+func (i *ImageController) QueryHead(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Second*10)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	if i.cfg.PresetsOnly {
+		return i.errorResponse(c, errPresetsOnly)
+	}
+
+	params, err := i.queryRequest(c)
+	if err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	image, err := i.service.HeadProcess(ctx, params, preconditions(c))
+	if err != nil {
+		logger.Error("Error getting image metadata", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	return sendHead(c, image)
+}
+
+// OriginalHead image metadata
+//
+//	@Summary		Get original image metadata
+//	@Description	Returns headers of the stored original without downloading it.
+//	@Tags			image
+//	@Param			entity	path	string	true	"Entity"
+//	@Param			file	path	string	true	"File name"
+//	@Success		200		"Headers only"
+//	@Success		304		"Image not modified"
+//	@Router			/images/{entity}/{file} [head]
+func (i *ImageController) OriginalHead(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Second*10)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	image, err := i.service.HeadOriginal(ctx, c.Params("entity"), c.Params("file"), preconditions(c))
+	if err != nil {
+		logger.Error("Error getting original metadata", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	return sendHead(c, image)
+}
+
+// ProxyHead image metadata
+//
+//	@Summary		Get proxied image metadata
+//	@Description	Returns headers of the proxied image from object metadata. Images missing in the cache are fetched and cached first. With DEBUG_HEADERS, X-Cache, X-Source and X-Cached-At describe the cached copy.
+//	@Tags			proxy
+//	@Param			service_type	path	string	true	"Service Type"
+//	@Param			path			path	string	true	"Path"
+//	@Success		200				"Headers only"
+//	@Success		304				"Image not modified"
+//	@Router			/{service_type}/{path} [head]
+func (i *ImageController) ProxyHead(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute*5)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	serviceType, err := model.MakeFromString(c.Params("service_type"))
+	if err != nil {
+		logger.Error("invalid service_type", zap.Error(err))
+		return err
+	}
+
+	image, err := i.service.HeadProxy(ctx, serviceType, c.Params("*"), preconditions(c))
+	if err != nil {
+		logger.Error("proxy service error", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	c.Set("Cache-Control", "max-age=604800,immutable")
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	i.setDebugHeaders(c, image.Provenance)
+	return sendHead(c, image)
+}
+
+// sendHead отдает заголовки без тела. SendStatus не используется: он записывает текст статуса в тело,
+// и Content-Length перестал бы соответствовать изображению
+func sendHead(c *fiber.Ctx, image *model.ImageResponse) error {
+	setValidators(c, image.ETag, image.LastModified)
+	if image.NotModified {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	// Размер еще не закешированного варианта неизвестен, и Content-Length тогда не отдается
+	c.Set(fiber.HeaderContentType, image.ContentType)
+	if image.ContentLength > 0 {
+		c.Set(fiber.HeaderContentLength, strconv.FormatInt(image.ContentLength, 10))
+	}
+	setDimensions(c, image)
+
+	c.Status(fiber.StatusOK)
+	return nil
+}
+
+// setDimensions выставляет размеры изображения, если они известны
+func setDimensions(c *fiber.Ctx, image *model.ImageResponse) {
+	if image.Width > 0 && image.Height > 0 {
+		c.Set("X-Image-Width", strconv.Itoa(image.Width))
+		c.Set("X-Image-Height", strconv.Itoa(image.Height))
+	}
+}
+
+// setDebugHeaders выставляет отладочные заголовки происхождения, если они включены DEBUG_HEADERS
+func (i *ImageController) setDebugHeaders(c *fiber.Ctx, provenance model.Provenance) {
+	if !i.cfg.DebugHeaders {
+		return
+	}
+
+	if provenance.Cache != "" {
+		c.Set("X-Cache", provenance.Cache)
+	}
+	if provenance.Source != "" {
+		c.Set("X-Source", provenance.Source)
+	}
+	if !provenance.CachedAt.IsZero() {
+		c.Set("X-Cached-At", provenance.CachedAt.UTC().Format(http.TimeFormat))
+	}
+}
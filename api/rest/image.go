@@ -15,22 +15,76 @@ import (
 	"time"
 )
 
+const proxyRoute = "/:service_type<regex(tmdb-images|kinopoisk-images|kinopoisk-ott-images|kinopoisk-st-images)>/*"
+
 type ImageController struct {
-	cfg     *config.Config
-	service *service.ImageService
-	logger  *zap.Logger
+	cfg      *config.Config
+	service  *service.ImageService
+	imgproxy imgproxySigner
+	logger   *zap.Logger
 }
 
 func NewImageController(app *fiber.App, cfg *config.Config, service *service.ImageService, logger *zap.Logger) *ImageController {
-	i := &ImageController{service: service, cfg: cfg, logger: logger}
+	i := &ImageController{
+		service:  service,
+		cfg:      cfg,
+		imgproxy: mustImgproxySigner(cfg.ImgproxyKey, cfg.ImgproxySalt, logger),
+		logger:   logger,
+	}
+
+	// Ошибка в пресете - ошибка конфигурации, поэтому проверяем их при старте, а не на первом запросе
+	for name := range cfg.Presets {
+		if _, err := model.PresetRequest("", "", cfg.Presets, name); err != nil {
+			logger.Panic("invalid preset", zap.Error(err))
+		}
+	}
 
+	// HEAD регистрируется раньше GET, иначе Fiber обработает его GET-хендлером с полным преобразованием
+	app.Head("/images/:entity/:file", i.OriginalHead)
+	app.Head("/images/:entity/:file/:width/:quality/:type", i.ProcessHead)
+	app.Head("/images/:entity/:file/p/:preset", i.PresetHead)
+	app.Head("/img/:entity/*", i.QueryHead)
+	app.Head(proxyRoute, i.ProxyHead)
+
+	app.Get("/images/:entity/:file", i.Original)
 	app.Get("/images/:entity/:file/:width/:quality/:type", i.Process)
-	app.Get("/:service_type<regex(tmdb-images|kinopoisk-images|kinopoisk-ott-images|kinopoisk-st-images)>/*", i.Proxy)
+	app.Get("/images/:entity/:file/p/:preset", i.Preset)
+	app.Get("/img/:entity/*", i.Query)
+	app.Get(proxyRoute, i.Proxy)
+
+	// Загрузка и удаление оригиналов требуют ADMIN_TOKEN
+	app.Post("/images/:entity/:file", i.authorize, i.Upload)
+	app.Put("/images/:entity/:file", i.authorize, i.Upload)
+	app.Delete("/images/:entity/:file", i.authorize, i.DeleteOriginal)
+
+	app.Get("/info", i.ServiceInfo)
+	app.Get("/info/images/:entity/:file", i.OriginalInfo)
+	app.Get("/info"+proxyRoute, i.ProxyInfo)
+
+	app.Get("/placeholder/images/:entity/:file", i.OriginalPlaceholder)
+	app.Get("/placeholder"+proxyRoute, i.ProxyPlaceholder)
+
+	app.Get("/srcset/images/:entity/:file", i.Srcset)
+
+	app.Get("/colors/images/:entity/:file", i.OriginalColors)
+	app.Get("/colors"+proxyRoute, i.ProxyColors)
 	
+	app.Post("/admin/ingest", i.authorize, i.Ingest)
+	app.Post("/admin/ingest/jobs", i.authorize, i.IngestJob)
+	app.Post("/admin/warmup", i.authorize, i.Warmup)
+	app.Get("/admin/jobs/:id", i.authorize, i.Job)
+
+	app.Delete("/admin/cache", i.authorize, i.PurgePrefix)
+	app.Delete("/admin/cache/images/:entity/:file", i.authorize, i.PurgeVariants)
+	app.Delete("/admin/cache"+proxyRoute, i.authorize, i.PurgeProxy)
+
 	// Административные эндпоинты для управления битыми URL
 	app.Get("/admin/failed-urls", i.GetFailedURLs)
 	app.Delete("/admin/failed-urls", i.ClearFailedURLs)
 
+	// Маршрут imgproxy регистрируется последним: при пустом префиксе он совпадает с любым путем
+	app.Get(cfg.ImgproxyPrefix+"/:signature/*", i.Imgproxy)
+
 	return i
 }
 
@@ -40,19 +94,39 @@ func NewImageController(app *fiber.App, cfg *config.Config, service *service.Ima
 //	@Description	Processes an image according to the specified parameters including entity, file, width, quality, and type.
 //	@Tags			image
 //	@Accept			json
-//	@Produce		image/jpeg,image/png,image/webp,image/avif
+//	@Produce		image/jpeg,image/png,image/webp,image/avif,image/gif,image/jxl
 //	@Param			entity	path	string	true	"Entity"
 //	@Param			file	path	string	true	"File name"
 //	@Param			width	path	int		true	"Width"
 //	@Param			quality	path	int		true	"Quality"
 //	@Param			type	path	string	true	"Image type"
+//	@Param			first_frame	query	bool	false	"Drop animation and keep only the first frame"
+//	@Param			dpr			query	number	false	"Device pixel ratio up to 4, capped by the source size"
+//	@Param			progressive	query	bool	false	"Progressive JPEG or interlaced PNG"
+//	@Param			trellis		query	bool	false	"mozjpeg trellis quantisation (jpeg)"
+//	@Param			subsampling	query	string	false	"Chroma subsampling (jpeg): auto, 420 or 444"
+//	@Param			lossless	query	bool	false	"Lossless encoding (webp, avif, jxl)"
+//	@Param			near_lossless	query	bool	false	"Near-lossless encoding (webp)"
+//	@Param			effort		query	int		false	"Encoder effort: webp 0-6, avif 0-9, jxl 1-9"
+//	@Param			palette		query	bool	false	"Palette quantisation (png)"
+//	@Param			compression	query	int		false	"zlib compression level 1-9 (png)"
+//	@Param			If-None-Match		header	string	false	"ETag from a previous response"
+//	@Param			If-Modified-Since	header	string	false	"Last-Modified from a previous response"
 //	@Success		200		{file}	file	"Returns the processed image"
+//	@Success		304		"Image not modified"
+//	@Failure		403		{object}	map[string]string	"Only presets are allowed"
+//	@Failure		422		{object}	map[string]string	"Image or requested size exceeds limits"
+//	@Failure		503		{object}	map[string]string	"Processing queue is full"
 //	@Router			/images/{entity}/{file}/{width}/{quality}/{type} [get]
 func (i *ImageController) Process(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(c.UserContext(), time.Second*10)
 	defer cancel()
 	logger := log.LoggerWithTrace(ctx, i.logger)
 
+	if i.cfg.PresetsOnly {
+		return i.errorResponse(c, errPresetsOnly)
+	}
+
 	params := &model.ImageRequest{}
 
 	err := c.ParamsParser(params)
@@ -61,15 +135,80 @@ func (i *ImageController) Process(c *fiber.Ctx) error {
 		return err
 
 	}
+	params.FirstFrame = c.QueryBool("first_frame")
+	params.DPR = float32(c.QueryFloat("dpr"))
+	if params.Options, err = model.ParseOverrides(c.Queries()); err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	if err = params.Validate(i.service.Limits()); err != nil {
+		logger.Warn("Invalid image params", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
 
 	logger.Debug(fmt.Sprintf("Processing image with params: %++v", params))
 
-	image, err := i.service.Process(ctx, *params)
+	image, err := i.service.Process(ctx, *params, preconditions(c))
 	if err != nil {
 		logger.Error("Error processing image", zap.Error(err))
-		return err
+		return i.errorResponse(c, err)
+	}
+
+	return sendImage(c, image)
+}
+
+// Preset image
+//
+//	@Summary		Process image with a named preset
+//	@Description	Processes an image with width, height, fit, quality, format and encoder options taken from a preset defined in the server configuration.
+//	@Tags			image
+//	@Produce		image/jpeg,image/png,image/webp,image/avif,image/gif,image/jxl
+//	@Param			entity				path	string	true	"Entity"
+//	@Param			file				path	string	true	"File name"
+//	@Param			preset				path	string	true	"Preset name, e.g. poster-sm"
+//	@Param			dpr					query	number	false	"Device pixel ratio up to 4, capped by the source size"
+//	@Param			If-None-Match		header	string	false	"ETag from a previous response"
+//	@Param			If-Modified-Since	header	string	false	"Last-Modified from a previous response"
+//	@Success		200					{file}	file	"Returns the processed image"
+//	@Success		304					"Image not modified"
+//	@Failure		404					{object}	map[string]string	"Unknown preset"
+//	@Failure		422					{object}	map[string]string	"Image or requested size exceeds limits"
+//	@Failure		503					{object}	map[string]string	"Processing queue is full"
+//	@Router			/images/{entity}/{file}/p/{preset} [get]
+func (i *ImageController) Preset(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Second*10)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	params, err := model.PresetRequest(c.Params("entity"), c.Params("file"), i.cfg.Presets, c.Params("preset"))
+	if err != nil {
+		logger.Warn("Error resolving preset", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+	params.DPR = float32(c.QueryFloat("dpr"))
+
+	if err = params.Validate(i.service.Limits()); err != nil {
+		logger.Warn("Invalid preset params", zap.Error(err))
+		return i.errorResponse(c, err)
 	}
 
+	image, err := i.service.Process(ctx, params, preconditions(c))
+	if err != nil {
+		logger.Error("Error processing image", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	return sendImage(c, image)
+}
+
+// sendImage отдает преобразованное изображение или 304, если клиентская копия актуальна
+func sendImage(c *fiber.Ctx, image *model.ImageResponse) error {
+	setValidators(c, image.ETag, image.LastModified)
+	if image.NotModified {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	setDimensions(c, image)
 	c.Type(image.Type)
 	c.Set("Content-Length", strconv.Itoa(int(image.ContentLength)))
 	c.Set("Content-Disposition", image.ContentDisposition)
@@ -77,16 +216,66 @@ func (i *ImageController) Process(c *fiber.Ctx) error {
 	return c.SendStream(image.Body)
 }
 
+// Original image
+//
+//	@Summary		Get original image
+//	@Description	Returns the stored original without transformation. Supports conditional and single-range requests.
+//	@Tags			image
+//	@Produce		image/jpeg,image/png,image/webp,image/avif,image/svg+xml
+//	@Param			entity				path	string	true	"Entity"
+//	@Param			file				path	string	true	"File name"
+//	@Param			If-None-Match		header	string	false	"ETag from a previous response"
+//	@Param			If-Modified-Since	header	string	false	"Last-Modified from a previous response"
+//	@Param			Range				header	string	false	"Single byte range, e.g. bytes=0-1023"
+//	@Success		200					{file}	file	"Returns the original image"
+//	@Success		206					{file}	file	"Returns the requested byte range"
+//	@Success		304					"Image not modified"
+//	@Failure		416					"Range not satisfiable"
+//	@Router			/images/{entity}/{file} [get]
+func (i *ImageController) Original(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	image, err := i.service.Original(ctx, c.Params("entity"), c.Params("file"), preconditions(c), model.ParseRange(c.Get(fiber.HeaderRange)))
+	if err != nil {
+		logger.Error("Error getting original image", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	setValidators(c, image.ETag, image.LastModified)
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	if image.NotModified {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Set(fiber.HeaderContentType, image.ContentType)
+	c.Set("Content-Length", strconv.Itoa(int(image.ContentLength)))
+	c.Set("Content-Disposition", image.ContentDisposition)
+
+	if image.ContentRange != "" {
+		c.Set(fiber.HeaderContentRange, image.ContentRange)
+		c.Status(fiber.StatusPartialContent)
+	}
+
+	return c.SendStream(image.Body)
+}
+
 // Proxy image
 //
 //	@Summary		Proxy image from a service
-//	@Description	Proxies an image from a specified external service based on the full request URL.
+//	@Description	Proxies an image from a specified external service based on the full request URL. With DEBUG_HEADERS, X-Cache (HIT or MISS), X-Source and X-Cached-At describe where the image came from.
 //	@Tags			proxy
 //	@Accept			json
 //	@Produce		image/jpeg,image/png,image/webp
 //	@Param			service_type	path	string	true	"Service Type"
 //	@Param			path			path	string	true	"Path"
+//	@Param			If-None-Match		header	string	false	"ETag from a previous response"
+//	@Param			If-Modified-Since	header	string	false	"Last-Modified from a previous response"
+//	@Param			Range				header	string	false	"Single byte range, e.g. bytes=0-1023"
 //	@Success		200				{file}	file	"Returns the proxied image"
+//	@Success		206				{file}	file	"Returns the requested byte range"
+//	@Success		304				"Image not modified"
 //	@Router			/{service_type}/{path} [get]
 func (i *ImageController) Proxy(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute*5)
@@ -99,8 +288,9 @@ func (i *ImageController) Proxy(c *fiber.Ctx) error {
 		return err
 	}
 	rawPath := c.Params("*")
+	cond := preconditions(c)
 
-	resp, err := i.service.ProxyImage(ctx, serviceType, rawPath)
+	resp, err := i.service.ProxyImage(ctx, serviceType, rawPath, cond, model.ParseRange(c.Get(fiber.HeaderRange)))
 	if err != nil {
 		logger.Error("proxy service error", zap.Error(err))
 		return err
@@ -112,7 +302,21 @@ func (i *ImageController) Proxy(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString("internal server error: nil response")
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode == http.StatusNotModified || cond.NotModified(resp.ETag, resp.LastModified) {
+		setValidators(c, resp.ETag, resp.LastModified)
+		c.Set("Cache-Control", "max-age=604800,immutable")
+		i.setDebugHeaders(c, resp.Provenance)
+		return c.SendStatus(http.StatusNotModified)
+	}
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		if contentRange := resp.Headers.Get(fiber.HeaderContentRange); contentRange != "" {
+			c.Set(fiber.HeaderContentRange, contentRange)
+		}
+		return c.SendStatus(resp.StatusCode)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
 		return c.SendStatus(resp.StatusCode)
 	}
 
@@ -129,7 +333,10 @@ func (i *ImageController) Proxy(c *fiber.Ctx) error {
 		}
 	}
 
+	setValidators(c, resp.ETag, resp.LastModified)
 	c.Set("Cache-Control", "max-age=604800,immutable")
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	i.setDebugHeaders(c, resp.Provenance)
 
 	// Защита от nil Body
 	if resp.Body == nil {
@@ -137,7 +344,7 @@ func (i *ImageController) Proxy(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString("internal server error: nil body")
 	}
 
-	return c.Status(http.StatusOK).SendStream(resp.Body)
+	return c.Status(resp.StatusCode).SendStream(resp.Body)
 }
 
 // GetFailedURLs возвращает файл с битыми URL
@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"resizer/api/model"
+	"resizer/shared/log"
+)
+
+var errInvalidSignature = errors.New("invalid signature")
+
+// imgproxySigner проверяет подписи imgproxy: base64url(HMAC-SHA256(key, salt + path)).
+// Без ключа подпись не проверяется, как и в самом imgproxy
+type imgproxySigner struct {
+	key  []byte
+	salt []byte
+}
+
+func mustImgproxySigner(hexKey, hexSalt string, logger *zap.Logger) imgproxySigner {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		logger.Panic("invalid IMGPROXY_KEY", zap.Error(err))
+	}
+
+	salt, err := hex.DecodeString(hexSalt)
+	if err != nil {
+		logger.Panic("invalid IMGPROXY_SALT", zap.Error(err))
+	}
+
+	return imgproxySigner{key: key, salt: salt}
+}
+
+func (s imgproxySigner) enabled() bool {
+	return len(s.key) > 0
+}
+
+func (s imgproxySigner) sign(path string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(s.salt)
+	mac.Write([]byte(path))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s imgproxySigner) verify(signature, path string) bool {
+	if !s.enabled() {
+		return true
+	}
+
+	return hmac.Equal([]byte(signature), []byte(s.sign(path)))
+}
+
+// Imgproxy image
+//
+//	@Summary		Process image by an imgproxy-style URL
+//	@Description	Parses imgproxy processing options (resize, size, resizing_type, width, height, quality, dpr, format, preset) and processes a bucket original (local:///entity/file, s3://bucket/entity/file) or a proxied upstream URL.
+//	@Tags			image
+//	@Produce		image/jpeg,image/png,image/webp,image/avif,image/gif,image/jxl
+//	@Param			signature	path	string	true	"URL signature, any value when signing is disabled"
+//	@Param			path		path	string	true	"Processing options and source, e.g. rs:fit:300:0/q:80/plain/local:///movie/1.jpg@webp"
+//	@Success		200			{file}	file	"Returns the processed image"
+//	@Success		304			"Image not modified"
+//	@Failure		403			{object}	map[string]string	"Invalid signature or only presets are allowed"
+//	@Failure		422			{object}	map[string]string	"Invalid URL or size exceeds limits"
+//	@Router			/imgproxy/{signature}/{path} [get]
+func (i *ImageController) Imgproxy(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	u, err := model.ParseImgproxyPath(strings.TrimPrefix(c.Path(), i.cfg.ImgproxyPrefix), i.cfg.Presets)
+	if err != nil {
+		logger.Warn("Invalid imgproxy URL", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	if !i.imgproxy.verify(u.Signature, u.SignedPath) {
+		return i.errorResponse(c, errInvalidSignature)
+	}
+
+	if i.cfg.PresetsOnly && !u.OnlyPresets {
+		return i.errorResponse(c, errPresetsOnly)
+	}
+
+	source, err := model.ResolveSource(u.Source, i.cfg.S3Bucket)
+	if err != nil {
+		logger.Warn("Unknown imgproxy source", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	params := u.Request
+	params.Entity, params.File = source.Entity, source.File
+	if err = params.Validate(i.service.Limits()); err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	var image *model.ImageResponse
+	if source.Proxied {
+		image, err = i.service.ProcessProxy(ctx, source.Service, source.File, params, preconditions(c))
+	} else {
+		image, err = i.service.Process(ctx, params, preconditions(c))
+	}
+	if err != nil {
+		logger.Error("Error processing imgproxy image", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	return sendImage(c, image)
+}
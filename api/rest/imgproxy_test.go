@@ -0,0 +1,61 @@
+package rest
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestImgproxySignerVerify(t *testing.T) {
+	// Пример подписи из документации imgproxy
+	signer := mustImgproxySigner(hex.EncodeToString([]byte("secret")), hex.EncodeToString([]byte("hello")), zap.NewNop())
+	const (
+		signedPath = "/rs:fill:300:400:0/g:sm/aHR0cDovL2V4YW1w/bGUuY29tL2ltYWdl/cy9jdXJpb3NpdHku/anBn.png"
+		signature  = "oKfUtW34Dvo2BGQehJFR4Nr0_rIjOtdtzJ3QFsUcXH8"
+	)
+
+	tests := []struct {
+		name      string
+		signature string
+		path      string
+		want      bool
+	}{
+		{"valid signature", signature, signedPath, true},
+		{"path changed", signature, "/rs:fill:301:400:0/g:sm/aHR0cDovL2V4YW1w/bGUuY29tL2ltYWdl/cy9jdXJpb3NpdHku/anBn.png", false},
+		{"path with traversal", signature, signedPath + "/../../posters/1.jpg", false},
+		{"signature changed", "pKfUtW34Dvo2BGQehJFR4Nr0_rIjOtdtzJ3QFsUcXH8", signedPath, false},
+		{"signature with padding", signature + "=", signedPath, false},
+		{"empty signature", "", signedPath, false},
+		{"unsafe placeholder", "insecure", signedPath, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signer.verify(tt.signature, tt.path); got != tt.want {
+				t.Errorf("verify(%q, %q) = %v, want %v", tt.signature, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImgproxySignerDisabled(t *testing.T) {
+	signer := mustImgproxySigner("", "", zap.NewNop())
+
+	if signer.enabled() {
+		t.Fatal("signer without a key must be disabled")
+	}
+	if !signer.verify("anything", "/w:100/plain/local:///movie/1.jpg") {
+		t.Error("signer without a key must accept any signature")
+	}
+}
+
+func TestMustImgproxySignerPanicsOnInvalidKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a non-hex key")
+		}
+	}()
+
+	mustImgproxySigner("not hex", "", zap.NewNop())
+}
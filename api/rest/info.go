@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"resizer/api/model"
+	"resizer/shared/log"
+)
+
+// OriginalInfo image metadata
+//
+//	@Summary		Get original image info
+//	@Description	Returns format, dimensions, orientation, color space, alpha, size and frame count of the stored original.
+//	@Tags			info
+//	@Produce		json
+//	@Param			entity	path		string	true	"Entity"
+//	@Param			file	path		string	true	"File name"
+//	@Success		200		{object}	model.ImageInfo
+//	@Failure		422		{object}	map[string]string	"Object is not a readable image"
+//	@Router			/info/images/{entity}/{file} [get]
+func (i *ImageController) OriginalInfo(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Second*30)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	info, err := i.service.OriginalInfo(ctx, c.Params("entity"), c.Params("file"))
+	if err != nil {
+		logger.Error("Error getting original info", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	return c.JSON(info)
+}
+
+// ProxyInfo image metadata
+//
+//	@Summary		Get proxied image info
+//	@Description	Returns format, dimensions, orientation, color space, alpha, size and frame count of the proxied image.
+//	@Tags			info
+//	@Produce		json
+//	@Param			service_type	path		string	true	"Service Type"
+//	@Param			path			path		string	true	"Path"
+//	@Success		200				{object}	model.ImageInfo
+//	@Failure		422				{object}	map[string]string	"Object is not a readable image"
+//	@Router			/info/{service_type}/{path} [get]
+func (i *ImageController) ProxyInfo(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute*5)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	serviceType, err := model.MakeFromString(c.Params("service_type"))
+	if err != nil {
+		logger.Error("invalid service_type", zap.Error(err))
+		return err
+	}
+
+	info, err := i.service.ProxyInfo(ctx, serviceType, c.Params("*"))
+	if err != nil {
+		logger.Error("Error getting proxy info", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	return c.JSON(info)
+}
+
+// ServiceInfo formats
+//
+//	@Summary		Get supported formats
+//	@Description	Returns the libvips version and which image formats can be loaded and saved by this build.
+//	@Tags			info
+//	@Produce		json
+//	@Success		200	{object}	model.ServiceInfo
+//	@Router			/info [get]
+func (i *ImageController) ServiceInfo(c *fiber.Ctx) error {
+	return c.JSON(i.service.Info())
+}
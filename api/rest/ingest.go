@@ -0,0 +1,108 @@
+package rest
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"resizer/api/model"
+	"resizer/shared/log"
+)
+
+// Ingest image by URL
+//
+//	@Summary		Ingest image by URL
+//	@Description	Fetches an image from a remote URL with the proxy safeguards (timeout, size limit, HTML and signature checks) and stores it as the original <entity>/<file>. Without replace an existing original is not overwritten. 201 is returned whenever the original did not exist before.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			request	body		model.IngestItem	true	"Source URL and target slot"
+//	@Success		200		{object}	model.ImageInfo		"Original replaced"
+//	@Success		201		{object}	model.ImageInfo		"Original created"
+//	@Failure		401		{object}	map[string]string	"Missing or invalid token"
+//	@Failure		409		{object}	map[string]string	"Original already exists"
+//	@Failure		422		{object}	map[string]string	"Invalid request, not an image or image exceeds limits"
+//	@Failure		502		{object}	map[string]string	"Source could not be fetched"
+//	@Router			/admin/ingest [post]
+func (i *ImageController) Ingest(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute*2)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	item := model.IngestItem{}
+	if err := c.BodyParser(&item); err != nil {
+		logger.Error("Error parsing body", zap.Error(err))
+		return err
+	}
+
+	if err := item.Validate(i.service.Limits()); err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	info, created, err := i.service.Ingest(ctx, item)
+	if err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	if created {
+		c.Status(fiber.StatusCreated)
+	}
+
+	return c.JSON(info)
+}
+
+// IngestJob starts batch ingest
+//
+//	@Summary		Start batch ingest by URL
+//	@Description	Starts a background job that ingests every item like /admin/ingest. Poll /admin/jobs/{id} for the status of each item. Jobs are kept in memory for an hour after they finish.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			request	body		model.IngestJobRequest	true	"Items to ingest, up to 1000"
+//	@Success		202		{object}	model.Job
+//	@Failure		401		{object}	map[string]string	"Missing or invalid token"
+//	@Failure		422		{object}	map[string]string	"Invalid item"
+//	@Router			/admin/ingest/jobs [post]
+func (i *ImageController) IngestJob(c *fiber.Ctx) error {
+	logger := log.LoggerWithTrace(c.UserContext(), i.logger)
+
+	request := model.IngestJobRequest{}
+	if err := c.BodyParser(&request); err != nil {
+		logger.Error("Error parsing body", zap.Error(err))
+		return err
+	}
+
+	if err := request.Validate(i.service.Limits()); err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	job := i.service.StartIngest(request.Items)
+	logger.Info("Ingest job started", zap.String("job", job.ID), zap.Int("items", job.Total))
+
+	c.Location("/admin/jobs/" + job.ID)
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// Job status
+//
+//	@Summary		Get background job status
+//	@Description	Returns the status of a background job and each of its items.
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string	true	"Job ID"
+//	@Success		200	{object}	model.Job
+//	@Failure		401	{object}	map[string]string	"Missing or invalid token"
+//	@Failure		404	{object}	map[string]string	"Unknown or expired job"
+//	@Router			/admin/jobs/{id} [get]
+func (i *ImageController) Job(c *fiber.Ctx) error {
+	job, err := i.service.Job(c.Params("id"))
+	if err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	return c.JSON(job)
+}
@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"resizer/api/model"
+	"resizer/shared/log"
+)
+
+// OriginalPlaceholder image placeholder
+//
+//	@Summary		Get original image placeholder
+//	@Description	Returns a BlurHash string and a tiny base64 LQIP data URI computed from a downscaled original.
+//	@Tags			placeholder
+//	@Produce		json
+//	@Param			entity	path		string	true	"Entity"
+//	@Param			file	path		string	true	"File name"
+//	@Success		200		{object}	model.Placeholder
+//	@Router			/placeholder/images/{entity}/{file} [get]
+func (i *ImageController) OriginalPlaceholder(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Second*30)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	placeholder, err := i.service.OriginalPlaceholder(ctx, c.Params("entity"), c.Params("file"))
+	if err != nil {
+		logger.Error("Error building placeholder", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	c.Set("Cache-Control", "max-age=604800")
+	return c.JSON(placeholder)
+}
+
+// ProxyPlaceholder image placeholder
+//
+//	@Summary		Get proxied image placeholder
+//	@Description	Returns a BlurHash string and a tiny base64 LQIP data URI computed from a downscaled proxied image.
+//	@Tags			placeholder
+//	@Produce		json
+//	@Param			service_type	path		string	true	"Service Type"
+//	@Param			path			path		string	true	"Path"
+//	@Success		200				{object}	model.Placeholder
+//	@Router			/placeholder/{service_type}/{path} [get]
+func (i *ImageController) ProxyPlaceholder(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute*5)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	serviceType, err := model.MakeFromString(c.Params("service_type"))
+	if err != nil {
+		logger.Error("invalid service_type", zap.Error(err))
+		return err
+	}
+
+	placeholder, err := i.service.ProxyPlaceholder(ctx, serviceType, c.Params("*"))
+	if err != nil {
+		logger.Error("Error building placeholder", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	c.Set("Cache-Control", "max-age=604800")
+	return c.JSON(placeholder)
+}
@@ -0,0 +1,103 @@
+package rest
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"resizer/api/model"
+	"resizer/shared/log"
+)
+
+// PurgeProxy cached upstream image
+//
+//	@Summary		Purge proxied image
+//	@Description	Deletes the cached upstream image proxy/{service_type}/{path} with all its variants and clears the libvips cache. The next request fetches it from the upstream again.
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			service_type	path		string	true	"Service Type"
+//	@Param			path			path		string	true	"Path"
+//	@Success		200				{object}	model.PurgeResponse
+//	@Failure		400				{object}	map[string]string	"Unknown service type"
+//	@Failure		401				{object}	map[string]string	"Missing or invalid token"
+//	@Failure		422				{object}	map[string]string	"Path contains empty, . or .. segments"
+//	@Router			/admin/cache/{service_type}/{path} [delete]
+func (i *ImageController) PurgeProxy(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	serviceType, err := model.MakeFromString(c.Params("service_type"))
+	if err != nil {
+		logger.Error("invalid service_type", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	response, err := i.service.PurgeProxy(ctx, serviceType, c.Params("*"))
+	if err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// PurgeVariants of an original
+//
+//	@Summary		Purge variants of an original
+//	@Description	Deletes all cached variants, placeholders and palettes of the original and clears the libvips cache. The original is kept.
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			entity	path		string	true	"Entity"
+//	@Param			file	path		string	true	"File name"
+//	@Success		200		{object}	model.PurgeResponse
+//	@Failure		401		{object}	map[string]string	"Missing or invalid token"
+//	@Failure		422		{object}	map[string]string	"Invalid entity or file"
+//	@Router			/admin/cache/images/{entity}/{file} [delete]
+func (i *ImageController) PurgeVariants(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute)
+	defer cancel()
+
+	entity, file := c.Params("entity"), c.Params("file")
+	if err := model.ValidateKey(entity, file); err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	response, err := i.service.PurgeVariants(ctx, entity, file)
+	if err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// PurgePrefix cache
+//
+//	@Summary		Purge cache by prefix
+//	@Description	Deletes every cached object under the prefix, e.g. proxy/kinopoisk-images/ for a whole service or variants/posters/ for an entity. Only proxy/ and variants/ prefixes are accepted, originals are never purged. Purging proxy/ also purges the matching variants. Objects are listed page by page and deleted in batches.
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			prefix	query		string	true	"Cache prefix starting with proxy/ or variants/"
+//	@Success		200		{object}	model.PurgeResponse
+//	@Failure		401		{object}	map[string]string	"Missing or invalid token"
+//	@Failure		422		{object}	map[string]string	"Prefix is not a cache prefix"
+//	@Router			/admin/cache [delete]
+func (i *ImageController) PurgePrefix(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute*10)
+	defer cancel()
+
+	prefix := c.Query("prefix")
+	if err := model.ValidatePurgePrefix(prefix); err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	response, err := i.service.PurgePrefix(ctx, prefix)
+	if err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	return c.JSON(response)
+}
@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"resizer/api/model"
+	"resizer/converter/image"
+	"resizer/shared/log"
+)
+
+// Query image
+//
+//	@Summary		Process image with query parameters
+//	@Description	Processes an image with optional parameters. Missing ones fall back to the source size, quality 80, WebP, cover fit and 1x density. The file may contain slashes.
+//	@Tags			image
+//	@Produce		image/jpeg,image/png,image/webp,image/avif,image/gif,image/jxl
+//	@Param			entity		path	string	true	"Entity"
+//	@Param			file		path	string	true	"File path"
+//	@Param			w			query	int		false	"Width"
+//	@Param			h			query	int		false	"Height"
+//	@Param			q			query	int		false	"Quality 1-100"
+//	@Param			fmt			query	string	false	"Output format: webp, avif, jpeg, png, gif, jxl"
+//	@Param			fit			query	string	false	"cover, contain or fill"
+//	@Param			dpr			query	number	false	"Device pixel ratio up to 4"
+//	@Param			first_frame	query	bool	false	"Drop animation and keep only the first frame"
+//	@Success		200			{file}	file	"Returns the processed image"
+//	@Success		304			"Image not modified"
+//	@Failure		403			{object}	map[string]string	"Only presets are allowed"
+//	@Failure		422			{object}	map[string]string	"Invalid parameters or size exceeds limits"
+//	@Failure		503			{object}	map[string]string	"Processing queue is full"
+//	@Router			/img/{entity}/{file} [get]
+func (i *ImageController) Query(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Second*10)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	if i.cfg.PresetsOnly {
+		return i.errorResponse(c, errPresetsOnly)
+	}
+
+	params, err := i.queryRequest(c)
+	if err != nil {
+		logger.Warn("Invalid image query", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	logger.Debug("Processing image by query", zap.Any("params", params))
+
+	image, err := i.service.Process(ctx, params, preconditions(c))
+	if err != nil {
+		logger.Error("Error processing image", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	return sendImage(c, image)
+}
+
+// queryRequest разбирает и проверяет параметры /img
+func (i *ImageController) queryRequest(c *fiber.Ctx) (model.ImageRequest, error) {
+	queries := c.Queries()
+	if err := model.CheckQueryKeys(queries); err != nil {
+		return model.ImageRequest{}, err
+	}
+
+	query := model.ImageQuery{}
+	if err := c.QueryParser(&query); err != nil {
+		return model.ImageRequest{}, &image.LimitError{Code: image.CodeInvalidOption, Message: err.Error()}
+	}
+
+	params, err := query.Request(c.Params("entity"), c.Params("*"))
+	if err != nil {
+		return model.ImageRequest{}, err
+	}
+
+	if params.Options, err = model.ParseOverrides(queries); err != nil {
+		return model.ImageRequest{}, err
+	}
+
+	return params, params.Validate(i.service.Limits())
+}
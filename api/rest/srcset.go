@@ -0,0 +1,172 @@
+package rest
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"resizer/api/model"
+	"resizer/converter/image"
+	"resizer/shared/log"
+)
+
+const maxSrcsetWidths = 10
+
+// srcsetDensities - плотности, для которых строятся варианты пресета
+var srcsetDensities = []float32{1, 2, 3}
+
+// Srcset manifest
+//
+//	@Summary		Get responsive srcset manifest
+//	@Description	Returns srcset and sizes for an original, either for a list of widths (w descriptors) or for a preset at 1x, 2x and 3x. URLs are signed imgproxy URLs when signing is enabled; the widths form then requires the admin bearer token.
+//	@Tags			image
+//	@Produce		json,html
+//	@Param			entity	path	string	true	"Entity"
+//	@Param			file	path	string	true	"File name"
+//	@Param			widths	query	string	false	"Comma-separated widths, e.g. 320,640,1280"
+//	@Param			preset	query	string	false	"Preset name, used instead of widths"
+//	@Param			fmt		query	string	false	"Output format for widths, webp by default"
+//	@Param			q		query	int		false	"Quality for widths, 80 by default"
+//	@Param			sizes	query	string	false	"sizes attribute for widths, 100vw by default"
+//	@Param			output	query	string	false	"json (default) or html"
+//	@Param			alt		query	string	false	"alt attribute for html output"
+//	@Success		200		{object}	model.Srcset
+//	@Failure		401		{object}	map[string]string	"Widths with signing enabled and no valid bearer token"
+//	@Failure		404		{object}	map[string]string	"Unknown preset"
+//	@Failure		422		{object}	map[string]string	"Invalid widths or parameters"
+//	@Router			/srcset/images/{entity}/{file} [get]
+func (i *ImageController) Srcset(c *fiber.Ctx) error {
+	logger := log.LoggerWithTrace(c.UserContext(), i.logger)
+	entity, file := c.Params("entity"), c.Params("file")
+
+	var (
+		entries []model.SrcsetEntry
+		err     error
+	)
+	if preset := c.Query("preset"); preset != "" {
+		entries, err = i.presetSrcset(c.BaseURL(), entity, file, preset)
+	} else {
+		entries, err = i.widthSrcset(c, entity, file)
+	}
+	if err != nil {
+		logger.Warn("Error building srcset", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	srcset := model.NewSrcset(entries, c.Query("sizes", "100vw"))
+	if c.Query("output") == "html" {
+		c.Type("html")
+		return c.SendString(srcset.HTML(c.Query("alt")))
+	}
+
+	return c.JSON(srcset)
+}
+
+// widthSrcset строит варианты с дескрипторами ширины для списка widths
+func (i *ImageController) widthSrcset(c *fiber.Ctx, entity, file string) ([]model.SrcsetEntry, error) {
+	if i.cfg.PresetsOnly {
+		return nil, errPresetsOnly
+	}
+
+	// С подписью ответ содержит подписанные URL для любых ширин, качества и формата,
+	// поэтому без токена такой маршрут подписывал бы что угодно. Пресеты остаются открытыми
+	if i.imgproxy.enabled() {
+		if err := i.checkToken(c); err != nil {
+			return nil, err
+		}
+	}
+
+	rawWidths := strings.Split(c.Query("widths"), ",")
+	if len(rawWidths) > maxSrcsetWidths {
+		return nil, &image.LimitError{Code: image.CodeInvalidOption, Message: fmt.Sprintf("at most %d widths are allowed", maxSrcsetWidths)}
+	}
+
+	query := model.ImageQuery{Format: c.Query("fmt"), Quality: float32(c.QueryInt("q"))}
+	entries := make([]model.SrcsetEntry, 0, len(rawWidths))
+	for _, rawWidth := range rawWidths {
+		width, err := strconv.Atoi(strings.TrimSpace(rawWidth))
+		if err != nil || width <= 0 {
+			return nil, &image.LimitError{Code: image.CodeInvalidWidth, Message: fmt.Sprintf("invalid width %q", rawWidth)}
+		}
+
+		query.Width = width
+		params, err := query.Request(entity, file)
+		if err != nil {
+			return nil, err
+		}
+		if err = params.Validate(i.service.Limits()); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, model.SrcsetEntry{URL: i.variantURL(c.BaseURL(), params, ""), Width: width})
+	}
+
+	return entries, nil
+}
+
+// presetSrcset строит варианты пресета для плотностей 1x-3x
+func (i *ImageController) presetSrcset(baseURL, entity, file, preset string) ([]model.SrcsetEntry, error) {
+	params, err := model.PresetRequest(entity, file, i.cfg.Presets, preset)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]model.SrcsetEntry, 0, len(srcsetDensities))
+	for _, density := range srcsetDensities {
+		params.DPR = density
+		entries = append(entries, model.SrcsetEntry{URL: i.variantURL(baseURL, params, preset), Density: density})
+	}
+
+	return entries, nil
+}
+
+// variantURL возвращает адрес варианта: подписанный URL imgproxy, если подпись включена,
+// иначе маршрут пресета или /img
+func (i *ImageController) variantURL(baseURL string, params model.ImageRequest, preset string) string {
+	dpr := ""
+	if params.DPR > 1 {
+		dpr = fmt.Sprintf("%g", params.DPR)
+	}
+
+	if i.imgproxy.enabled() {
+		options := fmt.Sprintf("/rs:%s:%d:%d/q:%g", imgproxyResizingType(params.Fit), params.Width, params.Height, params.Quality)
+		if preset != "" {
+			options = "/pr:" + preset
+		}
+		if dpr != "" {
+			options += "/dpr:" + dpr
+		}
+
+		path := fmt.Sprintf("%s/plain/local:///%s/%s@%s", options, params.Entity, url.PathEscape(params.File), params.Type.String())
+		return baseURL + i.cfg.ImgproxyPrefix + "/" + i.imgproxy.sign(path) + path
+	}
+
+	if preset != "" {
+		u := fmt.Sprintf("%s/images/%s/%s/p/%s", baseURL, params.Entity, url.PathEscape(params.File), preset)
+		if dpr != "" {
+			u += "?dpr=" + dpr
+		}
+		return u
+	}
+
+	query := url.Values{}
+	query.Set("w", strconv.Itoa(params.Width))
+	query.Set("q", fmt.Sprintf("%g", params.Quality))
+	query.Set("fmt", params.Type.String())
+	return fmt.Sprintf("%s/img/%s/%s?%s", baseURL, params.Entity, url.PathEscape(params.File), query.Encode())
+}
+
+// imgproxyResizingType - обратное отображение режима вписывания в тип ресайза imgproxy
+func imgproxyResizingType(fit image.Fit) string {
+	switch fit {
+	case image.FitContain:
+		return "fit"
+	case image.FitFill:
+		return "force"
+	default:
+		return "fill"
+	}
+}
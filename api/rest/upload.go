@@ -0,0 +1,126 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"resizer/api/model"
+	"resizer/shared/log"
+)
+
+// Upload original
+//
+//	@Summary		Upload original image
+//	@Description	Stores an original as <entity>/<file>. The body is either multipart with a "file" field or the raw image. HTML and non-images are rejected, the image is decoded to check the limits. POST fails if the original exists, PUT replaces it and purges its variants. 201 is returned whenever the original did not exist before. With max_width, max_height or strip the original is downscaled and re-encoded in its own format.
+//	@Tags			upload
+//	@Accept			multipart/form-data,image/jpeg,image/png,image/webp,image/avif,image/gif,image/heif,image/jxl
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			entity		path		string	true	"Entity"
+//	@Param			file		path		string	true	"File name"
+//	@Param			max_width	query		int		false	"Downscale to at most this width"
+//	@Param			max_height	query		int		false	"Downscale to at most this height"
+//	@Param			strip		query		bool	false	"Strip metadata according to the server settings"
+//	@Success		200			{object}	model.ImageInfo	"Original replaced"
+//	@Success		201			{object}	model.ImageInfo	"Original created"
+//	@Failure		401			{object}	map[string]string	"Missing or invalid token"
+//	@Failure		403			{object}	map[string]string	"Uploads are disabled"
+//	@Failure		409			{object}	map[string]string	"Original already exists"
+//	@Failure		413			{string}	string				"Body exceeds UPLOAD_MAX_BYTES"
+//	@Failure		422			{object}	map[string]string	"Not an image or image exceeds limits"
+//	@Router			/images/{entity}/{file} [post]
+//	@Router			/images/{entity}/{file} [put]
+func (i *ImageController) Upload(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	req := model.UploadRequest{}
+	if err := c.QueryParser(&req); err != nil {
+		logger.Error("Error parsing query", zap.Error(err))
+		return err
+	}
+	req.Entity, req.File = c.Params("entity"), c.Params("file")
+
+	if err := req.Validate(i.service.Limits()); err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	contentType, buf, err := uploadBody(c)
+	if err != nil {
+		logger.Error("Error reading upload body", zap.Error(err))
+		return err
+	}
+
+	replace := c.Method() == fiber.MethodPut
+	info, created, err := i.service.Upload(ctx, req, contentType, buf, replace)
+	if err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	if created {
+		c.Status(fiber.StatusCreated)
+	}
+
+	return c.JSON(info)
+}
+
+// uploadBody достает изображение из поля file multipart-формы или из тела запроса
+func uploadBody(c *fiber.Ctx) (string, []byte, error) {
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		// Буфер тела переиспользуется Fiber после ответа, поэтому копируем его
+		return c.Get(fiber.HeaderContentType), bytes.Clone(c.Body()), nil
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return "", nil, fiber.NewError(fiber.StatusBadRequest, `multipart field "file" is required`)
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	buf, err := io.ReadAll(file)
+	return header.Header.Get(fiber.HeaderContentType), buf, err
+}
+
+// DeleteOriginal original
+//
+//	@Summary		Delete original image
+//	@Description	Deletes the original together with all its cached variants, placeholders and palettes.
+//	@Tags			upload
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			entity	path		string	true	"Entity"
+//	@Param			file	path		string	true	"File name"
+//	@Success		200		{object}	model.DeleteResponse
+//	@Failure		401		{object}	map[string]string	"Missing or invalid token"
+//	@Failure		403		{object}	map[string]string	"Uploads are disabled"
+//	@Failure		404		{object}	map[string]string	"Original not found"
+//	@Router			/images/{entity}/{file} [delete]
+func (i *ImageController) DeleteOriginal(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute)
+	defer cancel()
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	entity, file := c.Params("entity"), c.Params("file")
+	if err := model.ValidateKey(entity, file); err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	response, err := i.service.DeleteOriginal(ctx, entity, file)
+	if err != nil {
+		logger.Error("Error deleting original", zap.Error(err))
+		return i.errorResponse(c, err)
+	}
+
+	return c.JSON(response)
+}
@@ -0,0 +1,47 @@
+package rest
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"resizer/api/model"
+	"resizer/shared/log"
+)
+
+// Warmup cache
+//
+//	@Summary		Warm up cache
+//	@Description	Starts a background job that fetches proxy paths (<service_type>/<path>) into the S3 cache and renders every width in every format for them and for originals (<entity>/<file>). Variants are rendered only when VARIANT_CACHE is enabled, existing ones are skipped. Poll /admin/jobs/{id} for progress, each source is one job item.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			request	body		model.WarmupRequest	true	"Sources and variants, formats default to webp and quality to 80"
+//	@Success		202		{object}	model.Job
+//	@Failure		401		{object}	map[string]string	"Missing or invalid token"
+//	@Failure		422		{object}	map[string]string	"Invalid source or variant"
+//	@Router			/admin/warmup [post]
+func (i *ImageController) Warmup(c *fiber.Ctx) error {
+	logger := log.LoggerWithTrace(c.UserContext(), i.logger)
+
+	request := model.WarmupRequest{}
+	if err := c.BodyParser(&request); err != nil {
+		logger.Error("Error parsing body", zap.Error(err))
+		return err
+	}
+
+	sources, err := request.Sources()
+	if err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	variants, err := request.Variants(i.service.Limits())
+	if err != nil {
+		return i.errorResponse(c, err)
+	}
+
+	job := i.service.StartWarmup(sources, variants)
+	logger.Info("Warmup job started", zap.String("job", job.ID), zap.Int("sources", len(sources)), zap.Int("variants", len(variants)))
+
+	c.Location("/admin/jobs/" + job.ID)
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/contrib/fiberzap/v2"
+	"github.com/gofiber/contrib/otelfiber/v2"
+	"github.com/gofiber/contrib/swagger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+	"github.com/gofiber/fiber/v2/middleware/healthcheck"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"go.uber.org/zap"
+	"resizer/api/model"
+	"resizer/api/rest"
+	"resizer/config"
+	"resizer/service"
+)
+
+const usage = `Usage: app <command> [flags] [args]
+
+Commands:
+  serve                             start the HTTP server (default)
+  warm [-widths 342,780] [-formats webp,avif] [-quality 80] <file>
+                                    warm up proxy paths (<service_type>/<path>) and originals
+                                    (<entity>/<file>) listed one per line in file
+  purge <prefix>                    delete cached objects under proxy/ or variants/ prefix
+  verify [-delete] <prefix>         find HTML and non-image objects under prefix, optionally delete them
+  scan [-prefix proxy/] [-action dry-run|delete|quarantine] [-quarantine quarantine/] [-rate 0] [-report file]
+                                    find empty, HTML, non-image, undecodable, too large and truncated
+                                    objects by their first and last bytes and write a JSON report
+  failed export [file]              write unique failed URLs to file or stdout
+  failed retry                      fetch failed URLs again, still failing ones are recorded again
+`
+
+// warmupPollInterval - как часто warm опрашивает состояние задачи
+const warmupPollInterval = time.Second
+
+// application - зависимости, общие для всех команд
+type application struct {
+	config  *config.Config
+	service *service.ImageService
+	logger  *zap.Logger
+}
+
+type command func(ctx context.Context, app *application, args []string) error
+
+var commands = map[string]command{
+	"serve":  serve,
+	"warm":   warm,
+	"purge":  purge,
+	"verify": verify,
+	"scan":   scan,
+	"failed": failed,
+}
+
+func serve(_ context.Context, app *application, _ []string) error {
+	fiberApp := fiber.New(fiber.Config{AppName: app.config.AppName, BodyLimit: app.config.UploadMaxBytes})
+	fiberApp.Use(
+		recover.New(),
+		otelfiber.Middleware(),
+		fiberzap.New(fiberzap.Config{Logger: app.logger}),
+		compress.New(compress.Config{Level: compress.LevelBestSpeed}),
+		limiter.New(limiter.Config{
+			Next: func(c *fiber.Ctx) bool {
+				return c.IP() == "127.0.0.1"
+			},
+			Max:        app.config.RateLimitMaxRequests,
+			Expiration: app.config.RateLimitDuration,
+		}),
+		healthcheck.New(healthcheck.Config{
+			LivenessProbe: func(c *fiber.Ctx) bool {
+				return true
+			},
+			ReadinessProbe: func(c *fiber.Ctx) bool {
+				return true
+			},
+		}),
+		swagger.New(swagger.Config{
+			BasePath: "/",
+			FilePath: "./docs/swagger.json",
+			Path:     "docs",
+			Title:    "OpenMovieDB Image Proxy service",
+		}),
+	)
+
+	rest.NewImageController(fiberApp, app.config, app.service, app.logger)
+
+	return fiberApp.Listen(":" + app.config.Port)
+}
+
+// warm прогревает кеш так же, как POST /admin/warmup, разбивая список на задачи допустимого размера
+func warm(ctx context.Context, app *application, args []string) error {
+	flags := flag.NewFlagSet("warm", flag.ContinueOnError)
+	widths := flags.String("widths", "", "comma-separated widths to render")
+	formats := flags.String("formats", "", "comma-separated formats, webp by default")
+	quality := flags.Float64("quality", 0, "quality, 80 by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("warm expects a file with paths")
+	}
+
+	lines, err := readLines(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	request := model.WarmupRequest{Quality: float32(*quality)}
+	for _, rawWidth := range splitList(*widths) {
+		width, err := strconv.Atoi(rawWidth)
+		if err != nil {
+			return fmt.Errorf("invalid width %q", rawWidth)
+		}
+		request.Widths = append(request.Widths, width)
+	}
+	request.Formats = splitList(*formats)
+
+	variants, err := request.Variants(app.service.Limits())
+	if err != nil {
+		return err
+	}
+
+	failedSources := 0
+	for start := 0; start < len(lines); start += model.MaxWarmupSources {
+		batch := request
+		batch.Proxy, batch.Images = nil, nil
+		for _, line := range lines[start:min(start+model.MaxWarmupSources, len(lines))] {
+			// Первый сегмент пути прокси - тип сервиса, все остальное считается оригиналом
+			rawService, _, _ := strings.Cut(strings.TrimPrefix(line, "/"), "/")
+			if _, err := model.MakeFromString(rawService); err == nil {
+				batch.Proxy = append(batch.Proxy, line)
+			} else {
+				batch.Images = append(batch.Images, line)
+			}
+		}
+
+		sources, err := batch.Sources()
+		if err != nil {
+			return err
+		}
+
+		job, err := waitJob(ctx, app, app.service.StartWarmup(sources, variants).ID)
+		if err != nil {
+			return err
+		}
+		if err = writeJSON(os.Stdout, job); err != nil {
+			return err
+		}
+		failedSources += job.Failed
+	}
+
+	if failedSources > 0 {
+		return fmt.Errorf("%d of %d sources failed", failedSources, len(lines))
+	}
+
+	return nil
+}
+
+// waitJob ждет завершения фоновой задачи, периодически сообщая о прогрессе
+func waitJob(ctx context.Context, app *application, id string) (model.Job, error) {
+	ticker := time.NewTicker(warmupPollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := app.service.Job(id)
+		if err != nil || job.FinishedAt != nil {
+			return job, err
+		}
+		app.logger.Info("job in progress", zap.String("job", id), zap.Int("completed", job.Completed), zap.Int("total", job.Total))
+
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func purge(ctx context.Context, app *application, args []string) error {
+	if len(args) != 1 {
+		return errors.New("purge expects a prefix")
+	}
+	if err := model.ValidatePurgePrefix(args[0]); err != nil {
+		return err
+	}
+
+	response, err := app.service.PurgePrefix(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(os.Stdout, response)
+}
+
+// verify - сокращение scan для одного префикса: отчет в stdout и удаление вместо переноса в карантин
+func verify(ctx context.Context, app *application, args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	remove := flags.Bool("delete", false, "delete bad objects")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("verify expects a prefix")
+	}
+
+	opts := model.VerifyOptions{Prefix: flags.Arg(0), Action: model.VerifyDryRun}
+	if *remove {
+		opts.Action = model.VerifyDelete
+	}
+
+	return runScan(ctx, app, opts, "")
+}
+
+func scan(ctx context.Context, app *application, args []string) error {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	prefix := flags.String("prefix", "proxy/", "prefix to scan")
+	action := flags.String("action", string(model.VerifyDryRun), "dry-run, delete or quarantine")
+	quarantine := flags.String("quarantine", "quarantine/", "prefix bad objects are moved to")
+	rate := flags.Int("rate", 0, "objects per second, 0 for no limit")
+	report := flags.String("report", "", "file for the JSON report, stdout by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	return runScan(ctx, app, model.VerifyOptions{
+		Prefix:           *prefix,
+		Action:           model.VerifyAction(*action),
+		QuarantinePrefix: *quarantine,
+		Rate:             *rate,
+	}, *report)
+}
+
+// runScan сканирует бакет и пишет отчет в файл или stdout. Отчет пишется и при прерванном сканировании
+func runScan(ctx context.Context, app *application, opts model.VerifyOptions, reportFile string) error {
+	// Удалять и переносить можно только кеш: битый оригинал требует ручного разбора
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	report, scanErr := app.service.Verify(ctx, opts)
+
+	out := io.Writer(os.Stdout)
+	if reportFile != "" {
+		file, err := os.Create(reportFile)
+		if err != nil {
+			return errors.Join(scanErr, err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	return errors.Join(scanErr, writeJSON(out, report))
+}
+
+func failed(ctx context.Context, app *application, args []string) error {
+	if len(args) == 0 {
+		return errors.New("failed expects export or retry")
+	}
+
+	switch args[0] {
+	case "export":
+		urls, err := app.service.FailedURLs()
+		if err != nil {
+			return err
+		}
+
+		out := io.Writer(os.Stdout)
+		if len(args) > 1 {
+			file, err := os.Create(args[1])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			out = file
+		}
+
+		for _, failedURL := range urls {
+			if _, err = fmt.Fprintln(out, failedURL); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "retry":
+		report, err := app.service.RetryFailedURLs(ctx)
+		if err != nil {
+			return err
+		}
+		return writeJSON(os.Stdout, report)
+	}
+
+	return fmt.Errorf("unknown failed subcommand %q", args[0])
+}
+
+// readLines читает непустые строки файла, строки с # пропускаются
+func readLines(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func writeJSON(out io.Writer, v any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
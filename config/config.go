@@ -6,6 +6,12 @@ import (
 	"time"
 )
 
+// Значения CACHE_GONE_POLICY
+const (
+	CacheGoneKeep   = "keep"
+	CacheGoneDelete = "delete"
+)
+
 type Config struct {
 	AppName string `env:"APP_NAME" envDefault:"OpenMovieDb image proxy"`
 	Port    string `env:"PORT" envDefault:"8080"`
@@ -13,7 +19,58 @@ type Config struct {
 	RateLimitMaxRequests int           `env:"RATE_LIMIT_MAX_REQUESTS" envDefault:"100"`
 	RateLimitDuration    time.Duration `env:"RATE_LIMIT_DURATION" envDefault:"1s"`
 
-	CacheTTL time.Duration `env:"CACHE_TTL" envDefault:"10m"`
+	// Закешированное изображение апстрима старше CacheTTL отдается сразу и перепроверяется в фоне, 0 отключает перепроверку.
+	// Копии без времени сохранения перепроверяются при первом обращении, поэтому включать лучше со сроком в днях.
+	// CacheGonePolicy определяет, что делать с копией, если апстрим ответил 404: keep или delete
+	CacheTTL        time.Duration `env:"CACHE_TTL" envDefault:"0"`
+	CacheGonePolicy string        `env:"CACHE_GONE_POLICY" envDefault:"keep"`
+
+	// Отладочные заголовки X-Cache, X-Source и X-Cached-At в ответах прокси
+	DebugHeaders bool `env:"DEBUG_HEADERS" envDefault:"false"`
+
+	// Сохранение обработанных вариантов в S3 под variants/. Каждое новое сочетание размера, качества,
+	// формата и опций кодировщика - отдельный объект, поэтому по умолчанию варианты не сохраняются
+	VariantCache bool `env:"VARIANT_CACHE" envDefault:"false"`
+
+	MaxInputMegapixels  float64 `env:"MAX_INPUT_MEGAPIXELS" envDefault:"50"`
+	MaxOutputWidth      int     `env:"MAX_OUTPUT_WIDTH" envDefault:"4096"`
+	MaxOutputHeight     int     `env:"MAX_OUTPUT_HEIGHT" envDefault:"4096"`
+	MaxOutputMegapixels float64 `env:"MAX_OUTPUT_MEGAPIXELS" envDefault:"16"`
+
+	ProcessConcurrency  int           `env:"PROCESS_CONCURRENCY" envDefault:"0"`
+	ProcessQueueSize    int           `env:"PROCESS_QUEUE_SIZE" envDefault:"64"`
+	ProcessQueueTimeout time.Duration `env:"PROCESS_QUEUE_TIMEOUT" envDefault:"5s"`
+
+	VipsConcurrency int `env:"VIPS_CONCURRENCY" envDefault:"0"`
+	VipsCacheMaxMem int `env:"VIPS_CACHE_MAX_MEM" envDefault:"104857600"`
+	VipsCacheMaxOps int `env:"VIPS_CACHE_MAX_OPS" envDefault:"500"`
+
+	StripMetadata   bool `env:"STRIP_METADATA" envDefault:"true"`
+	KeepCopyright   bool `env:"KEEP_COPYRIGHT" envDefault:"false"`
+	ConvertToSRGB   bool `env:"CONVERT_TO_SRGB" envDefault:"true"`
+	EmbedICCProfile bool `env:"EMBED_ICC_PROFILE" envDefault:"false"`
+
+	// Presets по умолчанию заменяются целиком, если задана переменная PRESETS
+	Presets     Presets `env:"PRESETS"`
+	PresetsOnly bool    `env:"PRESETS_ONLY" envDefault:"false"`
+
+	// Ключ и соль imgproxy в hex; без ключа подпись не проверяется
+	ImgproxyPrefix string `env:"IMGPROXY_PREFIX" envDefault:"/imgproxy"`
+	ImgproxyKey    string `env:"IMGPROXY_KEY"`
+	ImgproxySalt   string `env:"IMGPROXY_SALT"`
+
+	// Токен в заголовке Authorization: Bearer для загрузки и административных эндпоинтов; без него они отключены
+	AdminToken     string `env:"ADMIN_TOKEN"`
+	UploadMaxBytes int    `env:"UPLOAD_MAX_BYTES" envDefault:"33554432"`
+
+	// Лимит размера ответа внешних сервисов для прокси и загрузки по URL
+	FetchMaxBytes int `env:"FETCH_MAX_BYTES" envDefault:"33554432"`
+
+	// Разрешенные хосты исходящих запросов по апстримам; по умолчанию заменяются целиком, если задана переменная.
+	// Доверенным хостам (например, внутреннему прокси TMDB) разрешено обращаться к приватным адресам
+	FetchAllowedHosts HostAllowlist `env:"FETCH_ALLOWED_HOSTS"`
+	FetchTrustedHosts []string      `env:"FETCH_TRUSTED_HOSTS"`
+	FetchMaxRedirects int           `env:"FETCH_MAX_REDIRECTS" envDefault:"3"`
 
 	S3Region    string `env:"S3_REGION"`
 	S3Bucket    string `env:"S3_BUCKET,required"`
@@ -33,5 +90,19 @@ func New() *Config {
 		panic("Failed to parse config")
 	}
 
+	if conf.CacheGonePolicy != CacheGoneKeep && conf.CacheGonePolicy != CacheGoneDelete {
+		slog.Error("CACHE_GONE_POLICY must be keep or delete", "value", conf.CacheGonePolicy)
+
+		panic("Failed to parse config")
+	}
+
+	if conf.Presets == nil {
+		conf.Presets = defaultPresets()
+	}
+
+	if conf.FetchAllowedHosts == nil {
+		conf.FetchAllowedHosts = defaultHostAllowlist(conf.TMDBImageProxy)
+	}
+
 	return conf
 }
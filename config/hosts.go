@@ -0,0 +1,57 @@
+package config
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// HostAllowlist is read from the FETCH_ALLOWED_HOSTS variable as a JSON object keyed by upstream
+// (tmdb-images, kinopoisk-images, kinopoisk-ott-images, kinopoisk-st-images, ingest).
+// An upstream without a list may fetch from any public host, "*.example.com" allows subdomains.
+type HostAllowlist map[string][]string
+
+func (a *HostAllowlist) UnmarshalText(text []byte) error {
+	hosts := make(map[string][]string)
+	if err := json.Unmarshal(text, &hosts); err != nil {
+		return err
+	}
+
+	*a = hosts
+	return nil
+}
+
+// Allows reports whether the upstream may connect to host.
+func (a HostAllowlist) Allows(upstream, host string) bool {
+	patterns, ok := a[upstream]
+	if !ok || len(patterns) == 0 {
+		return true
+	}
+
+	host = strings.ToLower(host)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasSuffix(host, suffix) {
+			return true
+		}
+		if host == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+func defaultHostAllowlist(tmdbProxy string) HostAllowlist {
+	tmdb := []string{"www.themoviedb.org", "image.tmdb.org"}
+	if u, err := url.Parse(tmdbProxy); err == nil && u.Hostname() != "" {
+		tmdb = append(tmdb, u.Hostname())
+	}
+
+	return HostAllowlist{
+		"tmdb-images":          tmdb,
+		"kinopoisk-images":     {"avatars.mds.yandex.net"},
+		"kinopoisk-ott-images": {"avatars.mds.yandex.net"},
+		"kinopoisk-st-images":  {"st.kp.yandex.net"},
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import "testing"
+
+func TestHostAllowlistAllows(t *testing.T) {
+	allowlist := HostAllowlist{
+		"tmdb-images": {"image.tmdb.org", "*.Example.com"},
+		"ingest":      {},
+	}
+
+	tests := []struct {
+		name     string
+		upstream string
+		host     string
+		want     bool
+	}{
+		{"exact host", "tmdb-images", "image.tmdb.org", true},
+		{"host case is ignored", "tmdb-images", "IMAGE.tmdb.ORG", true},
+		{"other host", "tmdb-images", "evil.org", false},
+		{"suffix without dot boundary", "tmdb-images", "notimage.tmdb.org", false},
+		{"wildcard subdomain", "tmdb-images", "cdn.example.com", true},
+		{"wildcard nested subdomain", "tmdb-images", "a.b.example.com", true},
+		{"wildcard pattern case is ignored", "tmdb-images", "CDN.EXAMPLE.COM", true},
+		{"wildcard does not match apex", "tmdb-images", "example.com", false},
+		{"wildcard does not match lookalike", "tmdb-images", "badexample.com", false},
+		{"empty list allows any host", "ingest", "anything.org", true},
+		{"upstream without list allows any host", "kinopoisk-images", "anything.org", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allowlist.Allows(tt.upstream, tt.host); got != tt.want {
+				t.Errorf("Allows(%q, %q) = %v, want %v", tt.upstream, tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostAllowlistUnmarshalText(t *testing.T) {
+	var allowlist HostAllowlist
+	if err := allowlist.UnmarshalText([]byte(`{"ingest":["*.example.com"]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !allowlist.Allows("ingest", "img.example.com") || allowlist.Allows("ingest", "other.org") {
+		t.Errorf("unexpected allowlist %v", allowlist)
+	}
+
+	if err := allowlist.UnmarshalText([]byte(`["not", "an", "object"]`)); err == nil {
+		t.Error("expected an error for a JSON array")
+	}
+}
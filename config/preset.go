@@ -0,0 +1,41 @@
+package config
+
+import (
+	"encoding/json"
+)
+
+// Preset is a named transformation. Options use the same names as the encoder query parameters.
+type Preset struct {
+	Width   int               `json:"width"`
+	Height  int               `json:"height"`
+	Fit     string            `json:"fit"`
+	Quality float32           `json:"quality"`
+	Format  string            `json:"format"`
+	Options map[string]string `json:"options"`
+}
+
+// Presets is read from the PRESETS variable as a JSON object keyed by preset name.
+type Presets map[string]Preset
+
+func (p *Presets) UnmarshalText(text []byte) error {
+	presets := make(map[string]Preset)
+	if err := json.Unmarshal(text, &presets); err != nil {
+		return err
+	}
+
+	*p = presets
+	return nil
+}
+
+func defaultPresets() Presets {
+	return Presets{
+		"poster-sm": {Width: 342, Quality: 80, Format: "webp"},
+		"poster-lg": {Width: 780, Quality: 80, Format: "webp"},
+		"backdrop-hd": {
+			Width: 1280, Height: 720, Fit: "cover", Quality: 80, Format: "webp",
+		},
+		"avatar": {
+			Width: 185, Height: 185, Fit: "cover", Quality: 85, Format: "webp",
+		},
+	}
+}
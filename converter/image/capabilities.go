@@ -0,0 +1,40 @@
+package image
+
+import (
+	"sync"
+
+	"github.com/h2non/bimg"
+	"resizer/converter/image/format"
+)
+
+// Capability tells whether the linked libvips can load and save a format.
+type Capability struct {
+	Load bool `json:"load"`
+	Save bool `json:"save"`
+}
+
+// Capabilities is probed once: libvips loaders are fixed for the lifetime of the process.
+var Capabilities = sync.OnceValue(func() map[string]Capability {
+	return map[string]Capability{
+		"jpeg": bimgCapability(bimg.JPEG),
+		"png":  bimgCapability(bimg.PNG),
+		"webp": bimgCapability(bimg.WEBP),
+		"gif":  bimgCapability(bimg.GIF),
+		"avif": bimgCapability(bimg.AVIF),
+		"heif": bimgCapability(bimg.HEIF),
+		"svg":  {Load: bimg.IsTypeSupported(bimg.SVG)},
+		"jxl": {
+			Load: format.HasOperation("jxlload_buffer"),
+			Save: format.HasOperation("jxlsave_buffer"),
+		},
+	}
+})
+
+func bimgCapability(t bimg.ImageType) Capability {
+	return Capability{Load: bimg.IsTypeSupported(t), Save: bimg.IsTypeSupportedSave(t)}
+}
+
+// CanSave reports whether an encoder for t is registered and libvips can write it.
+func (s *Strategy) CanSave(t Type) bool {
+	return s.m[t] != nil && Capabilities()[t.s].Save
+}
@@ -0,0 +1,104 @@
+package image
+
+import (
+	"fmt"
+	stdimage "image"
+	"sort"
+)
+
+const colorSampleWidth = 64
+
+// Color is an average color of a palette bucket and the share of pixels it covers.
+type Color struct {
+	R, G, B uint8
+	Share   float64
+}
+
+func (c Color) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Palette returns up to size colors ordered by share, the first one is the dominant color.
+func (ci *CustomImage) Palette(size int) ([]Color, error) {
+	pixels, err := ci.sample(colorSampleWidth)
+	if err != nil {
+		return nil, err
+	}
+
+	return MedianCut(pixels, size), nil
+}
+
+// MedianCut quantizes opaque pixels of img into at most size buckets.
+func MedianCut(img stdimage.Image, size int) []Color {
+	bounds := img.Bounds()
+	pixels := make([][3]uint8, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			pixels = append(pixels, [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)})
+		}
+	}
+
+	if len(pixels) == 0 || size <= 0 {
+		return nil
+	}
+
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < size {
+		index, channel, spread := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if c, s := widestChannel(box); s > spread {
+				index, channel, spread = i, c, s
+			}
+		}
+		if index < 0 {
+			break
+		}
+
+		box := boxes[index]
+		sort.Slice(box, func(a, b int) bool { return box[a][channel] < box[b][channel] })
+		middle := len(box) / 2
+		boxes = append(boxes[:index], append([][][3]uint8{box[:middle], box[middle:]}, boxes[index+1:]...)...)
+	}
+
+	colors := make([]Color, 0, len(boxes))
+	for _, box := range boxes {
+		var r, g, b int
+		for _, p := range box {
+			r += int(p[0])
+			g += int(p[1])
+			b += int(p[2])
+		}
+		colors = append(colors, Color{
+			R:     uint8(r / len(box)),
+			G:     uint8(g / len(box)),
+			B:     uint8(b / len(box)),
+			Share: float64(len(box)) / float64(len(pixels)),
+		})
+	}
+
+	sort.SliceStable(colors, func(a, b int) bool { return colors[a].Share > colors[b].Share })
+
+	return colors
+}
+
+func widestChannel(box [][3]uint8) (channel, spread int) {
+	for c := 0; c < 3; c++ {
+		lo, hi := 255, 0
+		for _, p := range box {
+			lo = min(lo, int(p[c]))
+			hi = max(hi, int(p[c]))
+		}
+		if hi-lo > spread {
+			channel, spread = c, hi-lo
+		}
+	}
+
+	return channel, spread
+}
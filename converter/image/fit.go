@@ -0,0 +1,41 @@
+package image
+
+import (
+	"errors"
+)
+
+// Fit decides how an image is placed into a width x height box.
+type Fit struct {
+	s string
+}
+
+var (
+	// FitCover fills the box and crops the overflow around the centre.
+	FitCover = Fit{"cover"}
+	// FitContain keeps the whole image inside the box.
+	FitContain = Fit{"contain"}
+	// FitFill stretches the image to the box ignoring the aspect ratio.
+	FitFill = Fit{"fill"}
+)
+
+func (f *Fit) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "cover":
+		*f = FitCover
+	case "contain":
+		*f = FitContain
+	case "fill":
+		*f = FitFill
+	default:
+		return errors.New("unknown fit")
+	}
+	return nil
+}
+
+func (f *Fit) String() string {
+	if f.s == "" {
+		return FitCover.s
+	}
+
+	return f.s
+}
@@ -0,0 +1,85 @@
+package format
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+#include "metadata.h"
+
+// bimg loads only the first page, so animated images are resized by libvips directly.
+// thumbnail understands page-height and scales every frame of the "n=-1" strip.
+static int
+animated_thumbnail(void *buf, size_t len, int width, VipsImage **out) {
+	return vips_thumbnail_buffer(buf, len, out, width,
+		"height", 10000000,
+		"option_string", "n=-1",
+		NULL);
+}
+
+static int
+animated_webpsave(VipsImage *in, void **buf, size_t *len, int quality, int lossless, int near_lossless, int effort) {
+	return vips_webpsave_buffer(in, buf, len,
+		"Q", quality,
+		"lossless", lossless ? TRUE : FALSE,
+		"near_lossless", near_lossless ? TRUE : FALSE,
+		"effort", effort,
+		NULL);
+}
+
+static int
+animated_gifsave(VipsImage *in, void **buf, size_t *len) {
+	return vips_gifsave_buffer(in, buf, len, NULL);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"runtime"
+	"unsafe"
+)
+
+type animatedSaver func(in *C.VipsImage, buf *unsafe.Pointer, length *C.size_t) C.int
+
+// resizeAnimated scales every frame to width and saves the animation with the same
+// colour and metadata policy Transcode applies to still images.
+func resizeAnimated(buf []byte, width int, meta Metadata, save animatedSaver) ([]byte, error) {
+	if len(buf) == 0 {
+		return nil, errors.New("empty image buffer")
+	}
+	defer runtime.KeepAlive(buf)
+	defer C.vips_thread_shutdown()
+
+	var resized *C.VipsImage
+	if C.animated_thumbnail(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), C.int(width), &resized) != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_object_unref(C.gpointer(resized))
+
+	cMeta := meta.cMetadata()
+
+	var prepared *C.VipsImage
+	if C.apply_metadata(resized, &prepared, &cMeta) != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_object_unref(C.gpointer(prepared))
+
+	var ptr unsafe.Pointer
+	length := C.size_t(0)
+	if save(prepared, &ptr, &length) != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_free(C.gpointer(ptr))
+
+	return C.GoBytes(ptr, C.int(length)), nil
+}
+
+func saveAnimatedWebp(opts Options) animatedSaver {
+	return func(in *C.VipsImage, buf *unsafe.Pointer, length *C.size_t) C.int {
+		return C.animated_webpsave(in, buf, length,
+			C.int(opts.Quality), cBool(opts.Lossless), cBool(opts.NearLossless), C.int(opts.Effort))
+	}
+}
+
+func saveAnimatedGif(in *C.VipsImage, buf *unsafe.Pointer, length *C.size_t) C.int {
+	return C.animated_gifsave(in, buf, length)
+}
@@ -18,13 +18,19 @@ func MustAvif(logger *zap.Logger) *Avif {
 	return &Avif{logger: logger}
 }
 
-func (w *Avif) Encode(ctx context.Context, img *bimg.Image, quality float32) (io.Reader, int64, error) {
-	logger := log.LoggerWithTrace(ctx, w.logger)
+func (w *Avif) Defaults() Options {
+	return Options{Effort: 4}
+}
 
-	qualityAiff := 63 - int(quality/100*63)
-	logger.Debug(fmt.Sprintf("Converting image to avif with quality: %d", qualityAiff))
+func (w *Avif) Encode(ctx context.Context, img *bimg.Image, opts Options) (io.Reader, int64, error) {
+	logger := log.LoggerWithTrace(ctx, w.logger)
+	logger.Debug(fmt.Sprintf("Converting image to avif with options: %+v", opts))
 
-	buf, err := img.Process(bimg.Options{Type: bimg.AVIF, Quality: int(quality)})
+	buf, err := Transcode(img.Image(), suffix(".avif",
+		fmt.Sprintf("Q=%d", opts.Quality),
+		fmt.Sprintf("effort=%d", opts.Effort),
+		flag("lossless", opts.Lossless),
+	), opts.Metadata)
 	if err != nil {
 		logger.Error("Error converting image to avif", zap.Error(err))
 		return nil, 0, err
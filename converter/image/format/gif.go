@@ -0,0 +1,48 @@
+package format
+
+import (
+	"bytes"
+	"context"
+	"github.com/h2non/bimg"
+	"go.uber.org/zap"
+	"io"
+	"resizer/shared/log"
+)
+
+type Gif struct {
+	logger *zap.Logger
+}
+
+func MustGif(logger *zap.Logger) *Gif {
+	return &Gif{logger: logger}
+}
+
+func (w *Gif) Defaults() Options {
+	return Options{}
+}
+
+func (w *Gif) Encode(ctx context.Context, img *bimg.Image, opts Options) (io.Reader, int64, error) {
+	logger := log.LoggerWithTrace(ctx, w.logger)
+	logger.Debug("Converting image to gif")
+
+	buf, err := Transcode(img.Image(), ".gif", opts.Metadata)
+	if err != nil {
+		logger.Error("Error converting image to gif", zap.Error(err))
+		return nil, 0, err
+	}
+
+	return bytes.NewBuffer(buf), int64(len(buf)), nil
+}
+
+func (w *Gif) EncodeAnimated(ctx context.Context, buf []byte, width int, opts Options) (io.Reader, int64, error) {
+	logger := log.LoggerWithTrace(ctx, w.logger)
+	logger.Debug("Converting animated image to gif")
+
+	out, err := resizeAnimated(buf, width, opts.Metadata, saveAnimatedGif)
+	if err != nil {
+		logger.Error("Error converting animated image to gif", zap.Error(err))
+		return nil, 0, err
+	}
+
+	return bytes.NewBuffer(out), int64(len(out)), nil
+}
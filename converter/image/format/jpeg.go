@@ -18,11 +18,15 @@ func MustJpeg(logger *zap.Logger) *Jpeg {
 	return &Jpeg{logger: logger}
 }
 
-func (w *Jpeg) Encode(ctx context.Context, img *bimg.Image, quality float32) (io.Reader, int64, error) {
+func (w *Jpeg) Defaults() Options {
+	return Options{Interlace: true, Subsampling: SubsamplingAuto}
+}
+
+func (w *Jpeg) Encode(ctx context.Context, img *bimg.Image, opts Options) (io.Reader, int64, error) {
 	logger := log.LoggerWithTrace(ctx, w.logger)
-	logger.Debug(fmt.Sprintf("Converting image to jpeg with quality: %f", quality))
+	logger.Debug(fmt.Sprintf("Converting image to jpeg with options: %+v", opts))
 
-	buf, err := img.Process(bimg.Options{Type: bimg.JPEG, Quality: int(quality)})
+	buf, err := Transcode(img.Image(), jpegSuffix(opts), opts.Metadata)
 	if err != nil {
 		logger.Error("Error converting image to jpeg", zap.Error(err))
 		return nil, 0, err
@@ -30,3 +34,22 @@ func (w *Jpeg) Encode(ctx context.Context, img *bimg.Image, quality float32) (io
 
 	return bytes.NewBuffer(buf), int64(len(buf)), nil
 }
+
+func jpegSuffix(opts Options) string {
+	subsampling := ""
+	switch opts.Subsampling {
+	case Subsampling420:
+		subsampling = "subsample_mode=on"
+	case Subsampling444:
+		subsampling = "subsample_mode=off"
+	}
+
+	return suffix(".jpg",
+		fmt.Sprintf("Q=%d", opts.Quality),
+		"optimize_coding",
+		flag("interlace", opts.Interlace),
+		flag("trellis_quant,overshoot_deringing,quant_table=3", opts.Trellis),
+		flag("optimize_scans", opts.Trellis && opts.Interlace),
+		subsampling,
+	)
+}
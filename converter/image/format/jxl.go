@@ -0,0 +1,40 @@
+package format
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/h2non/bimg"
+	"go.uber.org/zap"
+	"io"
+	"resizer/shared/log"
+)
+
+type Jxl struct {
+	logger *zap.Logger
+}
+
+func MustJxl(logger *zap.Logger) *Jxl {
+	return &Jxl{logger: logger}
+}
+
+func (w *Jxl) Defaults() Options {
+	return Options{Effort: 7}
+}
+
+func (w *Jxl) Encode(ctx context.Context, img *bimg.Image, opts Options) (io.Reader, int64, error) {
+	logger := log.LoggerWithTrace(ctx, w.logger)
+	logger.Debug(fmt.Sprintf("Converting image to jxl with options: %+v", opts))
+
+	buf, err := Transcode(img.Image(), suffix(".jxl",
+		fmt.Sprintf("Q=%d", opts.Quality),
+		fmt.Sprintf("effort=%d", opts.Effort),
+		flag("lossless", opts.Lossless),
+	), opts.Metadata)
+	if err != nil {
+		logger.Error("Error converting image to jxl", zap.Error(err))
+		return nil, 0, err
+	}
+
+	return bytes.NewBuffer(buf), int64(len(buf)), nil
+}
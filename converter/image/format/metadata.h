@@ -0,0 +1,90 @@
+// Metadata policy shared by the still and animated savers.
+// Every cgo file that includes it gets its own static copy.
+
+#ifndef RESIZER_METADATA_H
+#define RESIZER_METADATA_H
+
+#include <string.h>
+#include "vips/vips.h"
+
+typedef struct {
+	int strip;
+	int keep_copyright;
+	int srgb;
+	int embed_profile;
+} save_metadata;
+
+static gboolean
+keep_field(const char *name, int keep_copyright) {
+	if (keep_copyright && (strcmp(name, VIPS_META_EXIF_NAME) == 0 ||
+		strcmp(name, "exif-ifd0-Copyright") == 0 ||
+		strcmp(name, "exif-ifd0-Artist") == 0)) {
+		return TRUE;
+	}
+
+	return !g_str_has_prefix(name, "exif-") &&
+		strcmp(name, VIPS_META_XMP_NAME) != 0 &&
+		strcmp(name, VIPS_META_IPTC_NAME) != 0 &&
+		strcmp(name, VIPS_META_PHOTOSHOP_NAME) != 0;
+}
+
+// libvips rebuilds exif-data from the remaining exif-ifd fields on save,
+// so removing the fields drops the tags while Copyright and Artist survive.
+static void
+strip_metadata(VipsImage *image, int keep_copyright) {
+	gchar **fields = vips_image_get_fields(image);
+	for (int i = 0; fields[i] != NULL; i++) {
+		if (!keep_field(fields[i], keep_copyright)) {
+			vips_image_remove(image, fields[i]);
+		}
+	}
+	g_strfreev(fields);
+}
+
+static int
+to_srgb(VipsImage *in, VipsImage **out, int embed_profile) {
+	VipsInterpretation interpretation = vips_image_guess_interpretation(in);
+
+	if (vips_image_get_typeof(in, VIPS_META_ICC_NAME)) {
+		if (vips_icc_transform(in, out, "srgb", "embedded", TRUE, "intent", VIPS_INTENT_PERCEPTUAL, NULL) == 0) {
+			return 0;
+		}
+		// libvips without lcms cannot apply profiles, a plain colourspace conversion is the best effort
+		vips_error_clear();
+	} else if (embed_profile && interpretation == VIPS_INTERPRETATION_sRGB) {
+		return vips_icc_transform(in, out, "srgb", "input_profile", "srgb", NULL);
+	}
+
+	if (interpretation == VIPS_INTERPRETATION_sRGB || interpretation == VIPS_INTERPRETATION_B_W) {
+		return vips_copy(in, out, NULL);
+	}
+
+	return vips_colourspace(in, out, VIPS_INTERPRETATION_sRGB, NULL);
+}
+
+// apply_metadata converts to sRGB and strips metadata as meta says. The result is a copy,
+// so removing fields never touches the input, which may be shared through the libvips cache.
+static int
+apply_metadata(VipsImage *in, VipsImage **out, save_metadata *meta) {
+	VipsImage *converted;
+	if (meta->srgb ? to_srgb(in, &converted, meta->embed_profile) : vips_copy(in, &converted, NULL)) {
+		return -1;
+	}
+
+	int code = vips_copy(converted, out, NULL);
+	g_object_unref(converted);
+	if (code) {
+		return -1;
+	}
+
+	if (meta->strip) {
+		strip_metadata(*out, meta->keep_copyright);
+	}
+	if (meta->srgb && !meta->embed_profile) {
+		vips_image_remove(*out, VIPS_META_ICC_NAME);
+	}
+
+	return 0;
+}
+
+#endif
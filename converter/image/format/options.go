@@ -0,0 +1,48 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Subsampling string
+
+const (
+	SubsamplingAuto Subsampling = "auto"
+	Subsampling420  Subsampling = "420"
+	Subsampling444  Subsampling = "444"
+)
+
+// Options configure a single encode. Encoders read only the fields their format supports.
+type Options struct {
+	Quality      int
+	Interlace    bool
+	Trellis      bool
+	Subsampling  Subsampling
+	Lossless     bool
+	NearLossless bool
+	Effort       int
+	Palette      bool
+	Compression  int
+	Metadata     Metadata
+}
+
+// suffix builds a libvips save option string such as ".jpg[Q=80,interlace]".
+func suffix(ext string, args ...string) string {
+	var parts []string
+	for _, arg := range args {
+		if arg != "" {
+			parts = append(parts, arg)
+		}
+	}
+
+	return fmt.Sprintf("%s[%s]", ext, strings.Join(parts, ","))
+}
+
+func flag(name string, on bool) string {
+	if on {
+		return name
+	}
+
+	return ""
+}
@@ -3,6 +3,7 @@ package format
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"github.com/h2non/bimg"
 	"go.uber.org/zap"
 	"io"
@@ -17,11 +18,21 @@ func MustPng(logger *zap.Logger) *Png {
 	return &Png{logger: logger}
 }
 
-func (w *Png) Encode(ctx context.Context, img *bimg.Image, quality float32) (io.Reader, int64, error) {
+func (w *Png) Defaults() Options {
+	return Options{Compression: 6}
+}
+
+func (w *Png) Encode(ctx context.Context, img *bimg.Image, opts Options) (io.Reader, int64, error) {
 	logger := log.LoggerWithTrace(ctx, w.logger)
-	logger.Debug("Converting image to png")
+	logger.Debug(fmt.Sprintf("Converting image to png with options: %+v", opts))
 
-	buf, err := img.Process(bimg.Options{Type: bimg.PNG, Quality: int(quality)})
+	// quality only matters for palette quantization
+	buf, err := Transcode(img.Image(), suffix(".png",
+		fmt.Sprintf("Q=%d", opts.Quality),
+		fmt.Sprintf("compression=%d", opts.Compression),
+		flag("interlace", opts.Interlace),
+		flag("palette", opts.Palette),
+	), opts.Metadata)
 	if err != nil {
 		logger.Error("Error converting image to png", zap.Error(err))
 		return nil, 0, err
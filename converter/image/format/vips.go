@@ -0,0 +1,134 @@
+package format
+
+/*
+#cgo pkg-config: vips
+#include <stdlib.h>
+#include "vips/vips.h"
+#include "metadata.h"
+
+static int
+probe_size(void *buf, size_t len, int *width, int *height) {
+	VipsImage *image = vips_image_new_from_buffer(buf, len, "", NULL);
+	if (image == NULL) {
+		return -1;
+	}
+
+	*width = vips_image_get_width(image);
+	*height = vips_image_get_height(image);
+	g_object_unref(image);
+
+	return 0;
+}
+
+// transcode applies the EXIF orientation the same way bimg does, then the colour and metadata policy.
+static int
+transcode(void *buf, size_t len, const char *suffix, save_metadata *meta, void **out, size_t *out_len) {
+	VipsImage *scope = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(scope), 3);
+
+	if (!(t[0] = vips_image_new_from_buffer(buf, len, "", NULL)) ||
+		vips_autorot(t[0], &t[1], NULL) ||
+		apply_metadata(t[1], &t[2], meta)) {
+		g_object_unref(scope);
+		return -1;
+	}
+
+	int code = vips_image_write_to_buffer(t[2], suffix, out, out_len, NULL);
+	g_object_unref(scope);
+
+	return code;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"runtime"
+	"unsafe"
+)
+
+// HasOperation reports whether libvips was built with the named operation, e.g. "jxlsave_buffer".
+func HasOperation(name string) bool {
+	basename := C.CString("VipsOperation")
+	defer C.free(unsafe.Pointer(basename))
+	nickname := C.CString(name)
+	defer C.free(unsafe.Pointer(nickname))
+
+	return C.vips_type_find(basename, nickname) != 0
+}
+
+// Probe reads image dimensions with the generic libvips loader, which decodes the header only.
+// It covers formats bimg does not know about.
+func Probe(buf []byte) (width, height int, err error) {
+	if len(buf) == 0 {
+		return 0, 0, errors.New("empty image buffer")
+	}
+	defer runtime.KeepAlive(buf)
+	defer C.vips_thread_shutdown()
+
+	var w, h C.int
+	if C.probe_size(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &w, &h) != 0 {
+		return 0, 0, vipsError()
+	}
+
+	return int(w), int(h), nil
+}
+
+// Metadata controls what a save keeps from the source. The zero value keeps everything as is.
+type Metadata struct {
+	Strip         bool
+	KeepCopyright bool
+	SRGB          bool
+	EmbedProfile  bool
+}
+
+// Transcode decodes buf with the generic libvips loader and saves it by suffix,
+// e.g. ".png" or ".jxl[Q=80]". The saver is picked at runtime, so formats
+// missing from the linked libvips fail here instead of at build time.
+// Encoders save through it rather than bimg, which exposes neither most saver
+// options nor selective metadata stripping.
+func Transcode(buf []byte, suffix string, meta Metadata) ([]byte, error) {
+	if len(buf) == 0 {
+		return nil, errors.New("empty image buffer")
+	}
+	defer runtime.KeepAlive(buf)
+	defer C.vips_thread_shutdown()
+
+	cSuffix := C.CString(suffix)
+	defer C.free(unsafe.Pointer(cSuffix))
+
+	cMeta := meta.cMetadata()
+
+	var ptr unsafe.Pointer
+	length := C.size_t(0)
+	if C.transcode(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), cSuffix, &cMeta, &ptr, &length) != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_free(C.gpointer(ptr))
+
+	return C.GoBytes(ptr, C.int(length)), nil
+}
+
+func (m Metadata) cMetadata() C.save_metadata {
+	return C.save_metadata{
+		strip:          cBool(m.Strip),
+		keep_copyright: cBool(m.KeepCopyright),
+		srgb:           cBool(m.SRGB),
+		embed_profile:  cBool(m.EmbedProfile),
+	}
+}
+
+func cBool(v bool) C.int {
+	if v {
+		return 1
+	}
+
+	return 0
+}
+
+func vipsError() error {
+	err := errors.New(C.GoString(C.vips_error_buffer()))
+	C.vips_error_clear()
+
+	return err
+}
@@ -18,11 +18,20 @@ func MustWebp(logger *zap.Logger) *Webp {
 	return &Webp{logger: logger}
 }
 
-func (w *Webp) Encode(ctx context.Context, img *bimg.Image, quality float32) (io.Reader, int64, error) {
+func (w *Webp) Defaults() Options {
+	return Options{Effort: 4}
+}
+
+func (w *Webp) Encode(ctx context.Context, img *bimg.Image, opts Options) (io.Reader, int64, error) {
 	logger := log.LoggerWithTrace(ctx, w.logger)
-	logger.Debug(fmt.Sprintf("Converting image to webp with quality: %f", quality))
+	logger.Debug(fmt.Sprintf("Converting image to webp with options: %+v", opts))
 
-	buf, err := img.Process(bimg.Options{Type: bimg.WEBP, Quality: int(quality)})
+	buf, err := Transcode(img.Image(), suffix(".webp",
+		fmt.Sprintf("Q=%d", opts.Quality),
+		fmt.Sprintf("effort=%d", opts.Effort),
+		flag("lossless", opts.Lossless),
+		flag("near_lossless", opts.NearLossless),
+	), opts.Metadata)
 	if err != nil {
 		logger.Error("Error converting image to webp", zap.Error(err))
 		return nil, 0, err
@@ -30,3 +39,16 @@ func (w *Webp) Encode(ctx context.Context, img *bimg.Image, quality float32) (io
 
 	return bytes.NewBuffer(buf), int64(len(buf)), nil
 }
+
+func (w *Webp) EncodeAnimated(ctx context.Context, buf []byte, width int, opts Options) (io.Reader, int64, error) {
+	logger := log.LoggerWithTrace(ctx, w.logger)
+	logger.Debug(fmt.Sprintf("Converting animated image to webp with options: %+v", opts))
+
+	out, err := resizeAnimated(buf, width, opts.Metadata, saveAnimatedWebp(opts))
+	if err != nil {
+		logger.Error("Error converting animated image to webp", zap.Error(err))
+		return nil, 0, err
+	}
+
+	return bytes.NewBuffer(out), int64(len(out)), nil
+}
@@ -0,0 +1,100 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// FrameCount returns the number of animation frames by walking the container structure
+// without decoding pixels. Still images and unknown formats report a single frame.
+func FrameCount(buf []byte) int {
+	var n int
+
+	switch {
+	case bytes.HasPrefix(buf, []byte("GIF87a")), bytes.HasPrefix(buf, []byte("GIF89a")):
+		n = gifFrames(buf)
+	case len(buf) >= 12 && bytes.Equal(buf[0:4], []byte("RIFF")) && bytes.Equal(buf[8:12], []byte("WEBP")):
+		n = webpFrames(buf)
+	case bytes.HasPrefix(buf, []byte("\x89PNG\r\n\x1a\n")):
+		n = apngFrames(buf)
+	}
+
+	return max(n, 1)
+}
+
+func gifFrames(buf []byte) int {
+	if len(buf) < 13 {
+		return 0
+	}
+
+	pos := 13
+	if flags := buf[10]; flags&0x80 != 0 {
+		pos += 3 << ((flags & 0x07) + 1)
+	}
+
+	frames := 0
+	for pos < len(buf) {
+		switch buf[pos] {
+		case 0x2C: // image descriptor
+			if pos+10 > len(buf) {
+				return frames
+			}
+			frames++
+			flags := buf[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << ((flags & 0x07) + 1)
+			}
+			pos++ // LZW minimum code size
+			pos = skipGifSubBlocks(buf, pos)
+		case 0x21: // extension
+			pos = skipGifSubBlocks(buf, pos+2)
+		default: // trailer or garbage
+			return frames
+		}
+	}
+
+	return frames
+}
+
+func skipGifSubBlocks(buf []byte, pos int) int {
+	for pos < len(buf) {
+		size := int(buf[pos])
+		pos++
+		if size == 0 {
+			return pos
+		}
+		pos += size
+	}
+
+	return pos
+}
+
+func webpFrames(buf []byte) int {
+	frames := 0
+	for pos := 12; pos+8 <= len(buf); {
+		size := int(binary.LittleEndian.Uint32(buf[pos+4 : pos+8]))
+		if bytes.Equal(buf[pos:pos+4], []byte("ANMF")) {
+			frames++
+		}
+		pos += 8 + size + size%2
+	}
+
+	return frames
+}
+
+func apngFrames(buf []byte) int {
+	for pos := 8; pos+8 <= len(buf); {
+		size := int(binary.BigEndian.Uint32(buf[pos : pos+4]))
+		chunk := buf[pos+4 : pos+8]
+		if bytes.Equal(chunk, []byte("acTL")) && pos+12 <= len(buf) {
+			return int(binary.BigEndian.Uint32(buf[pos+8 : pos+12]))
+		}
+		if bytes.Equal(chunk, []byte("IDAT")) {
+			return 0
+		}
+		pos += 12 + size
+	}
+
+	return 0
+}
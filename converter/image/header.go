@@ -0,0 +1,34 @@
+package image
+
+import (
+	"bytes"
+	stdimage "image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/h2non/bimg"
+	"resizer/converter/image/format"
+)
+
+// ReadHeader returns image dimensions without decoding pixel data.
+// JPEG, PNG and GIF headers are parsed in Go; other formats fall back to libvips,
+// which only reads the header until pixels are requested. Formats bimg does not know,
+// such as JPEG XL, go through the generic libvips loader.
+func ReadHeader(buf []byte) (bimg.ImageSize, error) {
+	if cfg, _, err := stdimage.DecodeConfig(bytes.NewReader(buf)); err == nil {
+		return bimg.ImageSize{Width: cfg.Width, Height: cfg.Height}, nil
+	}
+
+	size, err := bimg.Size(buf)
+	if err == nil {
+		return size, nil
+	}
+
+	width, height, probeErr := format.Probe(buf)
+	if probeErr != nil {
+		return bimg.ImageSize{}, newLimitError(CodeUnreadableImage, "cannot read image header: %v", err)
+	}
+
+	return bimg.ImageSize{Width: width, Height: height}, nil
+}
@@ -1,23 +1,42 @@
 package image
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"github.com/h2non/bimg"
+	stdimage "image"
+	"image/png"
 	"io"
+	"math"
+	"resizer/converter/image/format"
 )
 
 type Encoder interface {
-	Encode(ctx context.Context, img *bimg.Image, quality float32) (io.Reader, int64, error)
+	Defaults() format.Options
+	Encode(ctx context.Context, img *bimg.Image, opts format.Options) (io.Reader, int64, error)
 }
 
+// AnimatedEncoder is implemented by encoders that keep every frame of an animated source.
+type AnimatedEncoder interface {
+	EncodeAnimated(ctx context.Context, buf []byte, width int, opts format.Options) (io.Reader, int64, error)
+}
+
+var ErrAnimationUnsupported = errors.New("encoder does not support animation")
+
 type CustomImage struct {
-	img *bimg.Image
+	img    *bimg.Image
+	buf    []byte
+	size   bimg.ImageSize
+	frames int
 
-	t Encoder
+	t      Encoder
+	limits Limits
+	meta   format.Metadata
 }
 
-func NewCustomImage(t Encoder) *CustomImage {
-	return &CustomImage{t: t}
+func NewCustomImage(t Encoder, limits Limits, meta format.Metadata) *CustomImage {
+	return &CustomImage{t: t, limits: limits, meta: meta}
 }
 
 func (ci *CustomImage) Decode(reader io.Reader) (err error) {
@@ -26,17 +45,158 @@ func (ci *CustomImage) Decode(reader io.Reader) (err error) {
 		return err
 	}
 
+	kind := DetectFormat(buf)
+	if capability, ok := Capabilities()[kind]; ok && !capability.Load {
+		return newLimitError(CodeUnsupported, "%s input is not supported by libvips", kind)
+	}
+
+	ci.size, err = ReadHeader(buf)
+	if err != nil {
+		return err
+	}
+
+	if err = ci.limits.CheckInput(ci.size); err != nil {
+		return err
+	}
+
+	ci.frames = FrameCount(buf)
+
+	// bimg cannot load JPEG XL, and rotated sources must be upright before the target height
+	// is derived from the aspect ratio. Both are decoded once by libvips into a lossless PNG,
+	// which applies the orientation and keeps the metadata for the encoder to filter.
+	if kind == "jxl" || (ci.frames == 1 && orientation(buf) > 1) {
+		if buf, err = format.Transcode(buf, ".png[compression=1]", format.Metadata{}); err != nil {
+			return err
+		}
+		if ci.size, err = ReadHeader(buf); err != nil {
+			return err
+		}
+	}
+
+	ci.buf = buf
 	ci.img = bimg.NewImage(buf)
 
 	return nil
 }
 
+// orientation returns the EXIF orientation, 0 when the format has none.
+func orientation(buf []byte) int {
+	meta, err := bimg.Metadata(buf)
+	if err != nil {
+		return 0
+	}
+
+	return meta.Orientation
+}
+
+// ScaleForDPR multiplies the target size by the pixel ratio. The scale is capped so that
+// neither side exceeds the source or the output limits, the 1x size itself is never reduced.
+func (ci *CustomImage) ScaleForDPR(width, height int, dpr float32) (int, int) {
+	if dpr <= 1 || (width == 0 && height == 0) {
+		return width, height
+	}
+
+	scale := float64(dpr)
+	capTo := func(base, limit int) {
+		if base > 0 && limit > 0 {
+			scale = min(scale, max(1, float64(limit)/float64(base)))
+		}
+	}
+	capTo(width, ci.size.Width)
+	capTo(height, ci.size.Height)
+	capTo(width, ci.limits.MaxOutputWidth)
+	capTo(height, ci.limits.MaxOutputHeight)
+
+	return int(math.Round(float64(width) * scale)), int(math.Round(float64(height) * scale))
+}
+
+// BoundedWidth returns the width that fits the image into maxWidth x maxHeight
+// without enlarging it. Zero bounds are ignored.
+func (ci *CustomImage) BoundedWidth(maxWidth, maxHeight int) int {
+	scale := 1.0
+	if maxWidth > 0 {
+		scale = min(scale, float64(maxWidth)/float64(ci.size.Width))
+	}
+	if maxHeight > 0 {
+		scale = min(scale, float64(maxHeight)/float64(ci.size.Height))
+	}
+
+	return max(1, int(math.Floor(float64(ci.size.Width)*scale)))
+}
+
+// CheckOutput validates the target size against the limits before any resize is done.
+// A missing side is derived from the aspect ratio, with both missing the source size is kept.
+func (ci *CustomImage) CheckOutput(width, height int) error {
+	if width == 0 && height > 0 {
+		width = ci.size.Width * height / ci.size.Height
+	}
+
+	if width == 0 {
+		width = ci.size.Width
+	}
+
+	if height == 0 {
+		height = ci.size.Height * width / ci.size.Width
+	}
+
+	return ci.limits.CheckOutput(width, height)
+}
+
+// sample returns a downscaled copy of the image as Go pixels for color analysis.
+func (ci *CustomImage) sample(width int) (stdimage.Image, error) {
+	buf, err := ci.img.Process(bimg.Options{Width: width, Type: bimg.PNG, StripMetadata: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return png.Decode(bytes.NewReader(buf))
+}
+
 func (ci *CustomImage) Transform(funcs ...Transform) {
 	for _, f := range funcs {
 		ci.img = f(ci.img)
 	}
 }
 
-func (ci *CustomImage) Encode(ctx context.Context, quality float32) (io.Reader, int64, error) {
-	return ci.t.Encode(ctx, ci.img, quality)
+func (ci *CustomImage) Encode(ctx context.Context, quality float32, overrides Overrides) (io.Reader, int64, error) {
+	return ci.t.Encode(ctx, ci.img, ci.options(quality, overrides))
+}
+
+// options merges the encoder defaults with the request overrides.
+func (ci *CustomImage) options(quality float32, overrides Overrides) format.Options {
+	opts := overrides.Apply(ci.t.Defaults())
+	opts.Quality = int(quality)
+	opts.Metadata = ci.meta
+
+	return opts
+}
+
+// IsAnimated reports whether the source has more than one frame.
+func (ci *CustomImage) IsAnimated() bool {
+	return ci.frames > 1
+}
+
+// CanAnimate reports whether the target encoder can keep all frames.
+func (ci *CustomImage) CanAnimate() bool {
+	_, ok := ci.t.(AnimatedEncoder)
+	return ok
+}
+
+// EncodeAnimated resizes every frame to width and encodes the animation.
+// All frames are decoded, so the input pixel limit applies to their sum.
+func (ci *CustomImage) EncodeAnimated(ctx context.Context, width int, quality float32, overrides Overrides) (io.Reader, int64, error) {
+	encoder, ok := ci.t.(AnimatedEncoder)
+	if !ok {
+		return nil, 0, ErrAnimationUnsupported
+	}
+
+	if err := ci.limits.CheckInput(bimg.ImageSize{Width: ci.size.Width, Height: ci.size.Height * ci.frames}); err != nil {
+		return nil, 0, err
+	}
+
+	if width == 0 {
+		width = ci.size.Width
+	}
+
+	return encoder.EncodeAnimated(ctx, ci.buf, width, ci.options(quality, overrides))
 }
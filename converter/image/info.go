@@ -0,0 +1,31 @@
+package image
+
+import "github.com/h2non/bimg"
+
+// Info describes a source image as reported by libvips.
+type Info struct {
+	Format      string
+	Width       int
+	Height      int
+	Orientation int
+	ColorSpace  string
+	HasAlpha    bool
+	Frames      int
+}
+
+func Inspect(buf []byte) (Info, error) {
+	metadata, err := bimg.Metadata(buf)
+	if err != nil {
+		return Info{}, newLimitError(CodeUnreadableImage, "cannot read image metadata: %v", err)
+	}
+
+	return Info{
+		Format:      metadata.Type,
+		Width:       metadata.Size.Width,
+		Height:      metadata.Size.Height,
+		Orientation: metadata.Orientation,
+		ColorSpace:  metadata.Space,
+		HasAlpha:    metadata.Alpha,
+		Frames:      FrameCount(buf),
+	}, nil
+}
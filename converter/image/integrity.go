@@ -0,0 +1,53 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/h2non/bimg"
+)
+
+// verifyWidth is the width Verify renders to. The whole source is still decoded,
+// a small output only keeps the encode cheap.
+const verifyWidth = 64
+
+// TrailerSize is how many trailing bytes IsComplete needs to see.
+const TrailerSize = 32
+
+var pngEnd = []byte("IEND\xAE\x42\x60\x82")
+
+// IsComplete reports whether an object of the given size was stored in full, judging by the
+// end marker of its format: EOI for JPEG, IEND for PNG, the trailer for GIF and the RIFF size
+// for WebP. head must start at the first byte, tail must be the last TrailerSize bytes.
+// Formats without a cheap marker are assumed complete.
+func IsComplete(kind string, head, tail []byte, size int64) bool {
+	switch kind {
+	case "jpeg":
+		// Some encoders append padding after EOI
+		return bytes.Contains(tail, []byte{0xFF, 0xD9})
+	case "png":
+		return bytes.Contains(tail, pngEnd)
+	case "gif":
+		trimmed := bytes.TrimRight(tail, "\x00")
+		return len(trimmed) > 0 && trimmed[len(trimmed)-1] == 0x3B
+	case "webp":
+		if len(head) < 8 {
+			return false
+		}
+		// RIFF chunks are padded to an even size, so one extra byte is allowed
+		riffSize := int64(binary.LittleEndian.Uint32(head[4:8])) + 8
+		return size == riffSize || size == riffSize+1
+	}
+
+	return true
+}
+
+// Verify fully decodes the image with libvips. It is slow and meant for objects whose header
+// could not be read from a partial download, before they are reported as corrupt.
+func Verify(buf []byte) error {
+	if _, err := bimg.NewImage(buf).Process(bimg.Options{Width: verifyWidth, Type: bimg.PNG, StripMetadata: true}); err != nil {
+		return newLimitError(CodeUnreadableImage, "cannot decode image: %v", err)
+	}
+
+	return nil
+}
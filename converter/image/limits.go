@@ -0,0 +1,96 @@
+package image
+
+import (
+	"fmt"
+
+	"github.com/h2non/bimg"
+)
+
+const (
+	CodeInputTooLarge   = "input_too_large"
+	CodeOutputTooWide   = "output_too_wide"
+	CodeOutputTooTall   = "output_too_tall"
+	CodeOutputTooLarge  = "output_too_large"
+	CodeInvalidWidth    = "invalid_width"
+	CodeInvalidHeight   = "invalid_height"
+	CodeInvalidQuality  = "invalid_quality"
+	CodeUnreadableImage = "unreadable_image"
+	CodeUnsupported     = "unsupported_format"
+)
+
+// LimitError is returned when an image or a request exceeds the configured bounds.
+type LimitError struct {
+	Code    string
+	Message string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func newLimitError(code, format string, args ...any) *LimitError {
+	return &LimitError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Limits bounds the size of images accepted for decoding and produced by transforms.
+// Zero values disable the corresponding check.
+type Limits struct {
+	MaxInputPixels  int
+	MaxOutputWidth  int
+	MaxOutputHeight int
+	MaxOutputPixels int
+}
+
+func (l Limits) CheckInput(size bimg.ImageSize) error {
+	if size.Width <= 0 || size.Height <= 0 {
+		return newLimitError(CodeUnreadableImage, "invalid image dimensions %dx%d", size.Width, size.Height)
+	}
+
+	if l.MaxInputPixels > 0 && size.Width*size.Height > l.MaxInputPixels {
+		return newLimitError(CodeInputTooLarge, "input image %dx%d exceeds %d pixels", size.Width, size.Height, l.MaxInputPixels)
+	}
+
+	return nil
+}
+
+func (l Limits) CheckOutput(width, height int) error {
+	if l.MaxOutputWidth > 0 && width > l.MaxOutputWidth {
+		return newLimitError(CodeOutputTooWide, "output width %d exceeds %d", width, l.MaxOutputWidth)
+	}
+
+	if l.MaxOutputHeight > 0 && height > l.MaxOutputHeight {
+		return newLimitError(CodeOutputTooTall, "output height %d exceeds %d", height, l.MaxOutputHeight)
+	}
+
+	if l.MaxOutputPixels > 0 && width*height > l.MaxOutputPixels {
+		return newLimitError(CodeOutputTooLarge, "output image %dx%d exceeds %d pixels", width, height, l.MaxOutputPixels)
+	}
+
+	return nil
+}
+
+// CheckWidth validates the requested output width on its own, before the source is known.
+func (l Limits) CheckWidth(width int) error {
+	if width < 0 {
+		return newLimitError(CodeInvalidWidth, "width must not be negative, got %d", width)
+	}
+
+	if l.MaxOutputWidth > 0 && width > l.MaxOutputWidth {
+		return newLimitError(CodeOutputTooWide, "output width %d exceeds %d", width, l.MaxOutputWidth)
+	}
+
+	return nil
+}
+
+// CheckHeight validates the requested output height on its own, before the source is known.
+func (l Limits) CheckHeight(height int) error {
+	if height < 0 {
+		return newLimitError(CodeInvalidHeight, "height must not be negative, got %d", height)
+	}
+
+	if l.MaxOutputHeight > 0 && height > l.MaxOutputHeight {
+		return newLimitError(CodeOutputTooTall, "output height %d exceeds %d", height, l.MaxOutputHeight)
+	}
+
+	return nil
+}
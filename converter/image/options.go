@@ -0,0 +1,134 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	"resizer/converter/image/format"
+)
+
+const CodeInvalidOption = "invalid_option"
+
+// Overrides are per-request changes to the encoder defaults. Nil fields keep the default.
+type Overrides struct {
+	Interlace    *bool
+	Trellis      *bool
+	Subsampling  *format.Subsampling
+	Lossless     *bool
+	NearLossless *bool
+	Effort       *int
+	Palette      *bool
+	Compression  *int
+}
+
+type intRange struct{ min, max int }
+
+// optionRules lists which overrides each output type accepts and the allowed numeric ranges.
+var optionRules = map[Type]struct {
+	interlace, trellis, subsampling, lossless, nearLossless, palette bool
+	effort, compression                                              *intRange
+}{
+	JPEG: {interlace: true, trellis: true, subsampling: true},
+	WEBP: {lossless: true, nearLossless: true, effort: &intRange{0, 6}},
+	AVIF: {lossless: true, effort: &intRange{0, 9}},
+	PNG:  {interlace: true, palette: true, compression: &intRange{1, 9}},
+	JXL:  {lossless: true, effort: &intRange{1, 9}},
+	GIF:  {},
+}
+
+// Check rejects overrides the output type does not support or values outside the allowed range.
+func (o Overrides) Check(t Type) error {
+	rules := optionRules[t]
+	unsupported := func(name string) error {
+		return newLimitError(CodeInvalidOption, "%s is not supported for %s", name, t.String())
+	}
+
+	switch {
+	case o.Interlace != nil && !rules.interlace:
+		return unsupported("progressive")
+	case o.Trellis != nil && !rules.trellis:
+		return unsupported("trellis")
+	case o.Subsampling != nil && !rules.subsampling:
+		return unsupported("subsampling")
+	case o.Lossless != nil && !rules.lossless:
+		return unsupported("lossless")
+	case o.NearLossless != nil && !rules.nearLossless:
+		return unsupported("near_lossless")
+	case o.Palette != nil && !rules.palette:
+		return unsupported("palette")
+	case o.Effort != nil && rules.effort == nil:
+		return unsupported("effort")
+	case o.Compression != nil && rules.compression == nil:
+		return unsupported("compression")
+	}
+
+	if o.Effort != nil && (*o.Effort < rules.effort.min || *o.Effort > rules.effort.max) {
+		return newLimitError(CodeInvalidOption, "effort for %s must be between %d and %d, got %d",
+			t.String(), rules.effort.min, rules.effort.max, *o.Effort)
+	}
+
+	if o.Compression != nil && (*o.Compression < rules.compression.min || *o.Compression > rules.compression.max) {
+		return newLimitError(CodeInvalidOption, "compression for %s must be between %d and %d, got %d",
+			t.String(), rules.compression.min, rules.compression.max, *o.Compression)
+	}
+
+	return nil
+}
+
+// Apply returns the defaults with the overrides set on top.
+func (o Overrides) Apply(opts format.Options) format.Options {
+	if o.Interlace != nil {
+		opts.Interlace = *o.Interlace
+	}
+	if o.Trellis != nil {
+		opts.Trellis = *o.Trellis
+	}
+	if o.Subsampling != nil {
+		opts.Subsampling = *o.Subsampling
+	}
+	if o.Lossless != nil {
+		opts.Lossless = *o.Lossless
+	}
+	if o.NearLossless != nil {
+		opts.NearLossless = *o.NearLossless
+	}
+	if o.Effort != nil {
+		opts.Effort = *o.Effort
+	}
+	if o.Palette != nil {
+		opts.Palette = *o.Palette
+	}
+	if o.Compression != nil {
+		opts.Compression = *o.Compression
+	}
+
+	return opts
+}
+
+// Key is a stable representation of the set overrides for cache keys, empty when nothing is set.
+func (o Overrides) Key() string {
+	var parts []string
+	addBool := func(name string, v *bool) {
+		if v != nil {
+			parts = append(parts, fmt.Sprintf("%s%t", name, *v))
+		}
+	}
+	addInt := func(name string, v *int) {
+		if v != nil {
+			parts = append(parts, fmt.Sprintf("%s%d", name, *v))
+		}
+	}
+
+	addBool("i", o.Interlace)
+	addBool("t", o.Trellis)
+	if o.Subsampling != nil {
+		parts = append(parts, "s"+string(*o.Subsampling))
+	}
+	addBool("l", o.Lossless)
+	addBool("n", o.NearLossless)
+	addInt("e", o.Effort)
+	addBool("p", o.Palette)
+	addInt("c", o.Compression)
+
+	return strings.Join(parts, "_")
+}
@@ -0,0 +1,43 @@
+package image
+
+import (
+	"encoding/base64"
+
+	"github.com/h2non/bimg"
+)
+
+const (
+	blurHashSampleWidth = 32
+	blurHashComponentsX = 4
+	blurHashComponentsY = 3
+	lqipWidth           = 16
+	lqipQuality         = 40
+)
+
+// Placeholder is shown by clients while the full image loads.
+type Placeholder struct {
+	BlurHash string
+	LQIP     string
+	Width    int
+	Height   int
+}
+
+// Placeholder computes a BlurHash and a tiny JPEG data URI from a downscaled copy of the decoded image.
+func (ci *CustomImage) Placeholder() (Placeholder, error) {
+	pixels, err := ci.sample(blurHashSampleWidth)
+	if err != nil {
+		return Placeholder{}, err
+	}
+
+	lqip, err := ci.img.Process(bimg.Options{Width: lqipWidth, Type: bimg.JPEG, Quality: lqipQuality, StripMetadata: true})
+	if err != nil {
+		return Placeholder{}, err
+	}
+
+	return Placeholder{
+		BlurHash: BlurHash(pixels, blurHashComponentsX, blurHashComponentsY),
+		LQIP:     "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(lqip),
+		Width:    ci.size.Width,
+		Height:   ci.size.Height,
+	}, nil
+}
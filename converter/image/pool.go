@@ -0,0 +1,128 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrQueueFull    = errors.New("processing queue is full")
+	ErrQueueTimeout = errors.New("timed out waiting for a processing slot")
+)
+
+type PoolConfig struct {
+	Concurrency  int
+	QueueSize    int
+	QueueTimeout time.Duration
+}
+
+// Pool caps the number of concurrent libvips operations and sheds load
+// once the bounded wait queue is full.
+type Pool struct {
+	slots   chan struct{}
+	waiting atomic.Int64
+
+	queueSize    int64
+	queueTimeout time.Duration
+
+	waitTime metric.Float64Histogram
+
+	logger *zap.Logger
+}
+
+func MustPool(cfg PoolConfig, logger *zap.Logger) *Pool {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = runtime.NumCPU()
+	}
+
+	p := &Pool{
+		slots:        make(chan struct{}, cfg.Concurrency),
+		queueSize:    int64(cfg.QueueSize),
+		queueTimeout: cfg.QueueTimeout,
+		logger:       logger,
+	}
+
+	meter := otel.Meter("resizer/converter/image")
+
+	waitTime, err := meter.Float64Histogram("image.pool.wait_time",
+		metric.WithDescription("Time spent waiting for a processing slot"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		logger.Error("Error creating pool wait time histogram", zap.Error(err))
+	}
+	p.waitTime = waitTime
+
+	_, err = meter.Int64ObservableGauge("image.pool.queue_depth",
+		metric.WithDescription("Number of operations waiting for a processing slot"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(p.waiting.Load())
+			return nil
+		}),
+	)
+	if err != nil {
+		logger.Error("Error creating pool queue depth gauge", zap.Error(err))
+	}
+
+	_, err = meter.Int64ObservableGauge("image.pool.in_flight",
+		metric.WithDescription("Number of operations currently holding a processing slot"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(len(p.slots)))
+			return nil
+		}),
+	)
+	if err != nil {
+		logger.Error("Error creating pool in-flight gauge", zap.Error(err))
+	}
+
+	return p
+}
+
+// Do runs fn once a processing slot is available.
+// It fails fast with ErrQueueFull when the queue is full and with ErrQueueTimeout
+// when no slot frees up within the queue timeout.
+func (p *Pool) Do(ctx context.Context, fn func() error) error {
+	start := time.Now()
+
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		if err := p.wait(ctx); err != nil {
+			return err
+		}
+	}
+	defer func() { <-p.slots }()
+
+	if p.waitTime != nil {
+		p.waitTime.Record(ctx, float64(time.Since(start).Microseconds())/1000)
+	}
+
+	return fn()
+}
+
+func (p *Pool) wait(ctx context.Context) error {
+	if p.waiting.Add(1) > p.queueSize {
+		p.waiting.Add(-1)
+		return ErrQueueFull
+	}
+	defer p.waiting.Add(-1)
+
+	timer := time.NewTimer(p.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrQueueTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,64 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+var (
+	jxlCodestream = []byte{0xFF, 0x0A}
+	jxlContainer  = []byte{0x00, 0x00, 0x00, 0x0C, 'J', 'X', 'L', ' ', 0x0D, 0x0A, 0x87, 0x0A}
+)
+
+var heifBrands = map[string]bool{
+	"heic": true, "heix": true, "hevc": true, "hevx": true,
+	"heim": true, "heis": true, "mif1": true, "msf1": true,
+}
+
+// DetectFormat returns the image format by its magic bytes, or an empty string if unknown.
+func DetectFormat(buf []byte) string {
+	switch {
+	case len(buf) >= 2 && buf[0] == 0xFF && buf[1] == 0xD8:
+		return "jpeg"
+	case bytes.HasPrefix(buf, []byte("\x89PNG")):
+		return "png"
+	case bytes.HasPrefix(buf, []byte("GIF8")):
+		return "gif"
+	case len(buf) >= 12 && bytes.Equal(buf[:4], []byte("RIFF")) && bytes.Equal(buf[8:12], []byte("WEBP")):
+		return "webp"
+	case bytes.HasPrefix(buf, jxlCodestream), bytes.HasPrefix(buf, jxlContainer):
+		return "jxl"
+	}
+
+	return isoBrand(buf)
+}
+
+// isoBrand classifies ISO-BMFF files by the brands of the ftyp box.
+// mif1 is shared by HEIF and AVIF, so a compatible "avif" brand wins over the major brand.
+func isoBrand(buf []byte) string {
+	if len(buf) < 12 || !bytes.Equal(buf[4:8], []byte("ftyp")) {
+		return ""
+	}
+
+	size := int(binary.BigEndian.Uint32(buf[:4]))
+	if size < 16 || size > len(buf) {
+		size = min(len(buf), 64)
+	}
+
+	format := ""
+	for off := 8; off+4 <= size; off += 4 {
+		// bytes 12-15 hold the minor version, not a brand
+		if off == 12 {
+			continue
+		}
+
+		switch brand := string(buf[off : off+4]); {
+		case brand == "avif" || brand == "avis":
+			return "avif"
+		case heifBrands[brand] && format == "":
+			format = "heif"
+		}
+	}
+
+	return format
+}
@@ -28,6 +28,8 @@ func MustStrategy(logger *zap.Logger) *Strategy {
 		AVIF: format.MustAvif(logger),
 		JPEG: format.MustJpeg(logger),
 		PNG:  format.MustPng(logger),
+		GIF:  format.MustGif(logger),
+		JXL:  format.MustJxl(logger),
 	}}
 
 	return singleInstance
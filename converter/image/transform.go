@@ -31,3 +31,30 @@ func WithWidth(width int) Transform {
 		return img
 	}
 }
+
+// WithSize resizes to a box. Without a height it behaves like WithWidth,
+// without a width the width follows the aspect ratio.
+func WithSize(width, height int, fit Fit) Transform {
+	if height == 0 {
+		return WithWidth(width)
+	}
+
+	return func(img *bimg.Image) *bimg.Image {
+		options := bimg.Options{Width: width, Height: height, Enlarge: true}
+		switch fit {
+		case FitCover, Fit{}:
+			options.Crop = true
+			options.Gravity = bimg.GravityCentre
+		case FitFill:
+			options.Force = true
+		}
+
+		resizedImage, err := img.Process(options)
+		if err != nil {
+			zap.L().Error("Error resizing image", zap.Error(err))
+			return nil
+		}
+
+		return bimg.NewImage(resizedImage)
+	}
+}
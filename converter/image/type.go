@@ -13,6 +13,8 @@ var (
 	AVIF = Type{"avif"}
 	JPEG = Type{"jpeg"}
 	PNG  = Type{"png"}
+	GIF  = Type{"gif"}
+	JXL  = Type{"jxl"}
 	SVG  = Type{"svg"}
 )
 
@@ -26,6 +28,10 @@ func (t *Type) UnmarshalText(text []byte) error {
 		*t = Type{"jpeg"}
 	case "png":
 		*t = Type{"png"}
+	case "gif":
+		*t = Type{"gif"}
+	case "jxl":
+		*t = Type{"jxl"}
 	case "svg":
 		*t = Type{"svg"}
 	default:
@@ -37,3 +43,11 @@ func (t *Type) UnmarshalText(text []byte) error {
 func (t *Type) String() string {
 	return t.s
 }
+
+func (t *Type) MIME() string {
+	if *t == SVG {
+		return "image/svg+xml"
+	}
+
+	return "image/" + t.s
+}
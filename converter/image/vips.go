@@ -0,0 +1,45 @@
+package image
+
+/*
+#cgo pkg-config: vips
+#include "vips/vips.h"
+*/
+import "C"
+
+import (
+	"github.com/h2non/bimg"
+	"go.uber.org/zap"
+)
+
+type VipsConfig struct {
+	Concurrency int
+	CacheMaxMem int
+	CacheMaxOps int
+}
+
+// ConfigureVips overrides the libvips defaults set by bimg on startup.
+// Zero values keep the bimg defaults.
+func ConfigureVips(cfg VipsConfig, logger *zap.Logger) {
+	if cfg.Concurrency > 0 {
+		C.vips_concurrency_set(C.int(cfg.Concurrency))
+	}
+
+	if cfg.CacheMaxMem > 0 {
+		bimg.VipsCacheSetMaxMem(cfg.CacheMaxMem)
+	}
+
+	if cfg.CacheMaxOps > 0 {
+		bimg.VipsCacheSetMax(cfg.CacheMaxOps)
+	}
+
+	logger.Info("libvips configured",
+		zap.Int("concurrency", int(C.vips_concurrency_get())),
+		zap.Int("cache_max_mem", cfg.CacheMaxMem),
+		zap.Int("cache_max_ops", cfg.CacheMaxOps),
+	)
+}
+
+// DropVipsCache empties the libvips operation cache, the only in-memory tier of the service.
+func DropVipsCache() {
+	bimg.VipsCacheDropAll()
+}
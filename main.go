@@ -2,25 +2,18 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/gofiber/contrib/fiberzap/v2"
-	"github.com/gofiber/contrib/otelfiber/v2"
-	"github.com/gofiber/contrib/swagger"
-	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/compress"
-	"github.com/gofiber/fiber/v2/middleware/etag"
-	"github.com/gofiber/fiber/v2/middleware/healthcheck"
-	"github.com/gofiber/fiber/v2/middleware/limiter"
-	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/hyperdxio/otel-config-go/otelconfig"
+	"go.uber.org/zap"
 	"log/slog"
-	"resizer/api/rest"
 	"resizer/config"
 	img "resizer/converter/image"
 	"resizer/service"
@@ -32,8 +25,29 @@ import (
 //	@version		1.0
 //	@description	This is an API for OpenMovieDB Image Proxy service
 
+//	@securityDefinitions.apikey	BearerAuth
+//	@in							header
+//	@name						Authorization
+
 // @BasePath	/
 func main() {
+	os.Exit(run())
+}
+
+// run выполняет команду из аргументов, без аргументов запускается HTTP сервер.
+// Код выхода возвращается, а не передается в os.Exit, чтобы успели выполниться defer
+func run() int {
+	name, args := "serve", []string{}
+	if len(os.Args) > 1 {
+		name, args = os.Args[1], os.Args[2:]
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
 	serviceConfig := config.New()
 
 	ctx := context.Background()
@@ -58,6 +72,20 @@ func main() {
 		}
 	}()
 
+	imageService := newImageService(serviceConfig, logger)
+	// Команды завершаются раньше фоновых сохранений в S3, поэтому дожидаемся их
+	defer imageService.Flush()
+
+	if err = cmd(ctx, &application{config: serviceConfig, service: imageService, logger: logger}, args); err != nil {
+		logger.Error("command failed", zap.String("command", name), zap.Error(err))
+		return 1
+	}
+
+	return 0
+}
+
+// newImageService настраивает libvips, S3 и конвертер, общие для сервера и CLI
+func newImageService(serviceConfig *config.Config, logger *zap.Logger) *service.ImageService {
 	httpClient := &http.Client{
 		Transport: &http.Transport{
 			MaxIdleConns:        100,
@@ -77,46 +105,24 @@ func main() {
 		panic("Failed to create aws session")
 	}
 
+	img.ConfigureVips(img.VipsConfig{
+		Concurrency: serviceConfig.VipsConcurrency,
+		CacheMaxMem: serviceConfig.VipsCacheMaxMem,
+		CacheMaxOps: serviceConfig.VipsCacheMaxOps,
+	}, logger)
+
+	for name, capability := range img.Capabilities() {
+		logger.Info("image format support",
+			zap.String("format", name), zap.Bool("load", capability.Load), zap.Bool("save", capability.Save))
+	}
+
 	converterStrategy := img.MustStrategy(logger)
+	converterPool := img.MustPool(img.PoolConfig{
+		Concurrency:  serviceConfig.ProcessConcurrency,
+		QueueSize:    serviceConfig.ProcessQueueSize,
+		QueueTimeout: serviceConfig.ProcessQueueTimeout,
+	}, logger)
 	s3Client := s3.New(awsSession)
 
-	app := fiber.New(fiber.Config{AppName: serviceConfig.AppName})
-	app.Use(
-		recover.New(),
-		otelfiber.Middleware(),
-		fiberzap.New(fiberzap.Config{Logger: logger}),
-		compress.New(compress.Config{Level: compress.LevelBestSpeed}),
-		etag.New(),
-		limiter.New(limiter.Config{
-			Next: func(c *fiber.Ctx) bool {
-				return c.IP() == "127.0.0.1"
-			},
-			Max:        serviceConfig.RateLimitMaxRequests,
-			Expiration: serviceConfig.RateLimitDuration,
-		}),
-		healthcheck.New(healthcheck.Config{
-			LivenessProbe: func(c *fiber.Ctx) bool {
-				return true
-			},
-			ReadinessProbe: func(c *fiber.Ctx) bool {
-				return true
-			},
-		}),
-		swagger.New(swagger.Config{
-			BasePath: "/",
-			FilePath: "./docs/swagger.json",
-			Path:     "docs",
-			Title:    "OpenMovieDB Image Proxy service",
-		}),
-	)
-
-	imageService := service.NewImageService(s3Client, serviceConfig, converterStrategy, logger)
-
-	rest.NewImageController(app, serviceConfig, imageService, logger)
-
-	if err = app.Listen(":" + serviceConfig.Port); err != nil {
-		logger.Panic(err.Error())
-		return
-	}
+	return service.NewImageService(s3Client, serviceConfig, converterStrategy, converterPool, logger)
 }
-
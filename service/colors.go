@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"resizer/api/model"
+	"resizer/converter/image"
+)
+
+const (
+	colorsName  = "colors"
+	paletteSize = 6
+)
+
+// OriginalColors возвращает доминирующий цвет и палитру оригинала
+func (i *ImageService) OriginalColors(ctx context.Context, entity, file string) (*model.Colors, error) {
+	return derivedJSON(ctx, i, i.originalSource(entity, file), colorsName, buildColors)
+}
+
+// ProxyColors возвращает доминирующий цвет и палитру проксируемого изображения
+func (i *ImageService) ProxyColors(ctx context.Context, serviceType model.ServiceName, rawPath string) (*model.Colors, error) {
+	return derivedJSON(ctx, i, i.proxySource(serviceType, rawPath), colorsName, buildColors)
+}
+
+func buildColors(ci *image.CustomImage) (model.Colors, error) {
+	palette, err := ci.Palette(paletteSize)
+	if err != nil {
+		return model.Colors{}, err
+	}
+	if len(palette) == 0 {
+		return model.Colors{}, errors.New("image has no opaque pixels")
+	}
+
+	colors := model.Colors{Palette: make([]model.Color, 0, len(palette))}
+	for _, c := range palette {
+		colors.Palette = append(colors.Palette, model.Color{
+			Hex:   c.Hex(),
+			RGB:   [3]int{int(c.R), int(c.G), int(c.B)},
+			Share: c.Share,
+		})
+	}
+	colors.Dominant = colors.Palette[0]
+
+	return colors, nil
+}
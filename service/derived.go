@@ -0,0 +1,142 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"resizer/api/model"
+	"resizer/converter/image"
+	"resizer/shared/log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.uber.org/zap"
+)
+
+// imageSource описывает изображение, из которого строятся производные данные (плейсхолдеры, цвета)
+type imageSource struct {
+	key    string
+	prefix string
+	fetch  func(ctx context.Context) (buf []byte, etag string, err error)
+}
+
+func (i *ImageService) originalSource(entity, file string) imageSource {
+	key := fmt.Sprintf("%s/%s", entity, file)
+
+	return imageSource{
+		key:    key,
+		prefix: variantPrefix(entity, file),
+		fetch: func(ctx context.Context) ([]byte, string, error) {
+			result, err := i.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: &i.config.S3Bucket, Key: aws.String(key)})
+			if err != nil {
+				return nil, "", err
+			}
+			defer result.Body.Close()
+
+			buf, err := io.ReadAll(result.Body)
+			return buf, aws.StringValue(result.ETag), err
+		},
+	}
+}
+
+func (i *ImageService) proxySource(serviceType model.ServiceName, rawPath string) imageSource {
+	return imageSource{
+		key:    path.Join("proxy", serviceType.String(), rawPath),
+		prefix: variantPrefix(path.Join("proxy", serviceType.String()), rawPath),
+		fetch: func(ctx context.Context) ([]byte, string, error) {
+			resp, err := i.ProxyImage(ctx, serviceType, rawPath, model.Preconditions{}, model.Range{})
+			if err != nil {
+				return nil, "", err
+			}
+			if resp.StatusCode != http.StatusOK {
+				return nil, "", fmt.Errorf("unexpected proxy response status %d", resp.StatusCode)
+			}
+			return resp.rawBytes, resp.ETag, nil
+		},
+	}
+}
+
+// derivedKey возвращает ключ производного JSON рядом с вариантами источника
+func derivedKey(src imageSource, etag, name string) string {
+	return fmt.Sprintf("%s%s-%s.json", src.prefix, strings.Trim(etag, `"`), name)
+}
+
+// isDerivedKey сообщает, что ключ - производный JSON (плейсхолдер, палитра), а не изображение
+func isDerivedKey(key string) bool {
+	return strings.HasPrefix(key, "variants/") && strings.HasSuffix(key, ".json")
+}
+
+// derivedJSON отдает производные данные из S3 или строит их по декодированному источнику и кеширует.
+// ETag источника входит в ключ, поэтому после замены источника данные пересчитываются
+func derivedJSON[T any](ctx context.Context, i *ImageService, src imageSource, name string, build func(ci *image.CustomImage) (T, error)) (*T, error) {
+	logger := log.LoggerWithTrace(ctx, i.logger).With(zap.String("key", src.key), zap.String("derived", name))
+
+	head, err := i.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: &i.config.S3Bucket, Key: aws.String(src.key)})
+	if err != nil && !isNotFoundError(err) {
+		logger.Warn("Error getting source metadata from S3", zap.Error(err))
+	}
+
+	if err == nil && aws.StringValue(head.ETag) != "" {
+		cached, err := getDerivedJSON[T](ctx, i, derivedKey(src, aws.StringValue(head.ETag), name))
+		if err == nil {
+			return cached, nil
+		}
+		if !isNotFoundError(err) {
+			logger.Warn("Error getting derived data from S3", zap.Error(err))
+		}
+	}
+
+	buf, etag, err := src.fetch(ctx)
+	if err != nil {
+		logger.Error("Error fetching source image", zap.Error(err))
+		return nil, err
+	}
+
+	var result T
+	err = i.pool.Do(ctx, func() error {
+		customImage := image.NewCustomImage(nil, i.limits, i.metadata)
+		if err := customImage.Decode(bytes.NewReader(buf)); err != nil {
+			return err
+		}
+
+		var err error
+		result, err = build(customImage)
+		return err
+	})
+	if err != nil {
+		logger.Error("Error building derived data", zap.Error(err))
+		return nil, err
+	}
+
+	if etag != "" {
+		data, err := json.Marshal(result)
+		if err == nil {
+			resp := &ProxyResponse{Headers: http.Header{}, rawBytes: data, contentType: "application/json"}
+			i.cacheInBackground(i.config.S3Bucket, derivedKey(src, etag, name), resp, src.key)
+		}
+	}
+
+	return &result, nil
+}
+
+func getDerivedJSON[T any](ctx context.Context, i *ImageService, key string) (*T, error) {
+	result, err := i.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: &i.config.S3Bucket, Key: aws.String(key)})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	var cached T
+	if err = json.NewDecoder(result.Body).Decode(&cached); err != nil {
+		return nil, errors.Join(errors.New("corrupt derived data"), err)
+	}
+
+	return &cached, nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"resizer/api/model"
+	"resizer/shared/log"
+
+	"go.uber.org/zap"
+)
+
+// FailedURLs возвращает уникальные битые URL из файла в формате /service-type/path
+func (i *ImageService) FailedURLs() ([]string, error) {
+	i.failedURLsMutex.Lock()
+	defer i.failedURLsMutex.Unlock()
+
+	return readFailedURLs()
+}
+
+// readFailedURLs читает файл битых URL; вызывается под failedURLsMutex
+func readFailedURLs() ([]string, error) {
+	file, err := os.Open("failed_urls.txt")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var urls []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !seen[line] {
+			seen[line] = true
+			urls = append(urls, line)
+		}
+	}
+
+	return urls, scanner.Err()
+}
+
+// RetryFailedURLs заново запрашивает каждый битый URL через прокси. Файл не очищается заранее:
+// после повтора из него удаляются только загрузившиеся ссылки, поэтому прерванный повтор ничего не теряет,
+// а ссылки, записанные во время повтора, остаются
+func (i *ImageService) RetryFailedURLs(ctx context.Context) (*model.RetryReport, error) {
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	urls, err := i.FailedURLs()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.RetryReport{Total: len(urls)}
+	recovered := make(map[string]bool)
+	for _, failedURL := range urls {
+		rawService, rawPath, _ := strings.Cut(strings.TrimPrefix(failedURL, "/"), "/")
+		serviceType, err := model.MakeFromString(rawService)
+		if err != nil {
+			logger.Warn("skipping failed URL with unknown service", zap.String("url", failedURL))
+			report.Failed++
+			continue
+		}
+
+		resp, err := i.ProxyImage(ctx, serviceType, rawPath, model.Preconditions{}, model.Range{})
+		if err != nil || resp.StatusCode != http.StatusOK || !i.isValidImageResponse(resp) {
+			report.Failed++
+			continue
+		}
+		report.Recovered++
+		recovered[failedURL] = true
+	}
+
+	if err = i.removeFailedURLs(recovered); err != nil {
+		logger.Error("ошибка перезаписи файла битых URL", zap.Error(err))
+		return report, err
+	}
+
+	logger.Info("failed URLs retried", zap.Int("total", report.Total), zap.Int("recovered", report.Recovered), zap.Int("failed", report.Failed))
+
+	return report, nil
+}
+
+// removeFailedURLs переписывает файл битых URL без загрузившихся ссылок. Новый файл пишется рядом
+// и заменяет старый переименованием, чтобы сбой при записи не оставил файл пустым
+func (i *ImageService) removeFailedURLs(recovered map[string]bool) error {
+	if len(recovered) == 0 {
+		return nil
+	}
+
+	i.failedURLsMutex.Lock()
+	defer i.failedURLsMutex.Unlock()
+
+	urls, err := readFailedURLs()
+	if err != nil {
+		return err
+	}
+
+	var content strings.Builder
+	for _, failedURL := range urls {
+		if !recovered[failedURL] {
+			content.WriteString(failedURL + "\n")
+		}
+	}
+
+	if err = os.WriteFile("failed_urls.txt.tmp", []byte(content.String()), 0644); err != nil {
+		return err
+	}
+	if err = os.Rename("failed_urls.txt.tmp", "failed_urls.txt"); err != nil {
+		return err
+	}
+
+	// Открытый дескриптор указывает на замененный файл, новые ссылки должны попадать в новый
+	if i.failedURLsFile != nil {
+		i.failedURLsFile.Close()
+	}
+	i.failedURLsFile, err = os.OpenFile("failed_urls.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	return err
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"resizer/api/model"
+	"resizer/shared/log"
+
+	"go.uber.org/zap"
+)
+
+// fetchTimeout ограничивает весь запрос к внешнему сервису вместе с чтением тела
+const fetchTimeout = 30 * time.Second
+
+// upstreamStatusError - ответ внешнего сервиса с кодом, отличным от 200
+type upstreamStatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *upstreamStatusError) Error() string {
+	return fmt.Sprintf("external service returned status %d for %s", e.StatusCode, e.URL)
+}
+
+// fetchURL загружает объект по URL целиком через защищенный клиент. Хосты проверяются по списку
+// апстрима upstream, ответ не больше FETCH_MAX_BYTES, ошибкой считается любой код, кроме 200, и пустое тело.
+// С условиями cond ответ 304 возвращается как ProxyResponse без тела
+func (i *ImageService) fetchURL(ctx context.Context, upstream, url string, cond model.Preconditions) (*ProxyResponse, error) {
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	req, err := http.NewRequestWithContext(withUpstream(ctx, upstream), "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cond.IfNoneMatch != "" {
+		req.Header.Set("If-None-Match", cond.IfNoneMatch)
+	}
+	if !cond.IfModifiedSince.IsZero() {
+		req.Header.Set("If-Modified-Since", cond.IfModifiedSince.UTC().Format(http.TimeFormat))
+	}
+
+	err = i.guard.checkURL(upstream, req.URL)
+	var res *http.Response
+	if err == nil {
+		res, err = i.client.Do(req)
+	}
+	if errors.Is(err, ErrBlockedDestination) {
+		logger.Warn("outbound request blocked", zap.String("code", CodeBlockedDestination),
+			zap.String("upstream", upstream), zap.String("url", url), zap.Error(err))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified && cond != (model.Preconditions{}) {
+		return &ProxyResponse{StatusCode: http.StatusNotModified, UpstreamETag: res.Header.Get("ETag")}, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		// Возвращаем error вместо пустого response, чтобы избежать nil pointers
+		return nil, &upstreamStatusError{StatusCode: res.StatusCode, URL: url}
+	}
+
+	maxBytes := int64(i.config.FetchMaxBytes)
+	if maxBytes > 0 && res.ContentLength > maxBytes {
+		return nil, fmt.Errorf("external service response of %d bytes exceeds %d for %s", res.ContentLength, maxBytes, url)
+	}
+
+	// Content-Length может отсутствовать или быть неверным, поэтому читаем на байт больше лимита
+	body := io.Reader(res.Body)
+	if maxBytes > 0 {
+		body = io.LimitReader(res.Body, maxBytes+1)
+	}
+	bodyBytes, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if maxBytes > 0 && int64(len(bodyBytes)) > maxBytes {
+		return nil, fmt.Errorf("external service response exceeds %d bytes for %s", maxBytes, url)
+	}
+
+	// Проверяем, что получили данные
+	if len(bodyBytes) == 0 {
+		return nil, fmt.Errorf("external service returned empty body for %s", url)
+	}
+
+	lastModified, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+
+	headers := make(http.Header)
+	contentType := res.Header.Get("Content-Type")
+	if contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+	headers.Set("Content-Length", fmt.Sprint(len(bodyBytes)))
+
+	// ETag совпадает с тем, что S3 присвоит объекту при однократной загрузке (MD5 тела),
+	// поэтому повторная валидация клиентом сработает и после кеширования
+	return &ProxyResponse{
+		Body:         io.NopCloser(bytes.NewReader(bodyBytes)),
+		Headers:      headers,
+		StatusCode:   http.StatusOK,
+		ETag:         fmt.Sprintf(`"%x"`, md5.Sum(bodyBytes)),
+		LastModified: time.Now(),
+		rawBytes:     bodyBytes,
+		contentType:  contentType,
+
+		Provenance:           model.Provenance{Cache: model.CacheMiss, Source: url},
+		UpstreamETag:         res.Header.Get("ETag"),
+		UpstreamLastModified: lastModified,
+	}, nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"resizer/config"
+)
+
+// CodeBlockedDestination помечает в логах и ответах запросы, остановленные защитой от SSRF
+const CodeBlockedDestination = "blocked_destination"
+
+var ErrBlockedDestination = errors.New("outbound request blocked")
+
+// blockedPrefixes - диапазоны, которых нет среди проверок netip: CGNAT (там же метаданные Alibaba Cloud),
+// "этот" сеть 0.0.0.0/8, служебные 192.0.0.0/24, тестовые 198.18.0.0/15 и зарезервированные 240.0.0.0/4
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	// NAT64 для локального использования может транслироваться куда угодно
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// nat64Prefix и sixToFourPrefix содержат IPv4 внутри IPv6: в последних 32 битах и в битах 16-48.
+// Такие адреса проверяются по вложенному IPv4, иначе через шлюз трансляции доступна внутренняя сеть
+var (
+	nat64Prefix     = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFourPrefix = netip.MustParsePrefix("2002::/16")
+)
+
+type upstreamContextKey struct{}
+
+// outboundGuard проверяет исходящие запросы: схему и хост по списку апстрима на каждом редиректе
+// и адреса, в которые разрешается хост, непосредственно перед подключением
+type outboundGuard struct {
+	allowlist    config.HostAllowlist
+	trusted      []string
+	maxRedirects int
+	dialer       *net.Dialer
+}
+
+func newOutboundGuard(c *config.Config) *outboundGuard {
+	return &outboundGuard{
+		allowlist:    c.FetchAllowedHosts,
+		trusted:      c.FetchTrustedHosts,
+		maxRedirects: c.FetchMaxRedirects,
+		dialer:       &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second},
+	}
+}
+
+// client возвращает HTTP клиент, все подключения и редиректы которого проходят через guard.
+// Прокси из окружения не используется, иначе проверялся бы адрес прокси, а не апстрима
+func (g *outboundGuard) client(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = g.dialContext
+
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     transport,
+		CheckRedirect: g.checkRedirect,
+	}
+}
+
+// withUpstream запоминает апстрим запроса, чтобы проверять по его списку и адреса редиректов
+func withUpstream(ctx context.Context, upstream string) context.Context {
+	return context.WithValue(ctx, upstreamContextKey{}, upstream)
+}
+
+func (g *outboundGuard) checkURL(upstream string, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q is not allowed", ErrBlockedDestination, u.Scheme)
+	}
+
+	if !g.allowlist.Allows(upstream, u.Hostname()) {
+		return fmt.Errorf("%w: host %q is not allowed for %s", ErrBlockedDestination, u.Hostname(), upstream)
+	}
+
+	return nil
+}
+
+func (g *outboundGuard) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > g.maxRedirects {
+		return fmt.Errorf("%w: stopped after %d redirects", ErrBlockedDestination, g.maxRedirects)
+	}
+
+	upstream, _ := req.Context().Value(upstreamContextKey{}).(string)
+	return g.checkURL(upstream, req.URL)
+}
+
+// dialContext сам разрешает хост и подключается к проверенному адресу, поэтому повторное
+// разрешение DNS с другим ответом (DNS rebinding) не обходит проверку
+func (g *outboundGuard) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Хост, у которого хотя бы один адрес внутренний, отклоняется целиком
+	if !g.isTrusted(host) {
+		for _, ip := range ips {
+			if isBlockedIP(ip) {
+				return nil, fmt.Errorf("%w: %s resolves to %s", ErrBlockedDestination, host, ip.Unmap())
+			}
+		}
+	}
+
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := g.dialer.DialContext(ctx, network, net.JoinHostPort(ip.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses for %s", host)
+	}
+
+	return nil, lastErr
+}
+
+func (g *outboundGuard) isTrusted(host string) bool {
+	return slices.ContainsFunc(g.trusted, func(trusted string) bool {
+		return strings.EqualFold(trusted, host)
+	})
+}
+
+// isBlockedIP отклоняет loopback, приватные, link-local (включая 169.254.169.254 облачных метаданных),
+// multicast и зарезервированные адреса. IPv4 внутри IPv6 (mapped, NAT64, 6to4) проверяется как IPv4
+func isBlockedIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if embedded, ok := embeddedIPv4(ip); ok {
+		ip = embedded
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func embeddedIPv4(ip netip.Addr) (netip.Addr, bool) {
+	b := ip.As16()
+	switch {
+	case nat64Prefix.Contains(ip):
+		return netip.AddrFrom4([4]byte(b[12:16])), true
+	case sixToFourPrefix.Contains(ip):
+		return netip.AddrFrom4([4]byte(b[2:6])), true
+	}
+
+	return netip.Addr{}, false
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsBlockedIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"169.254.169.254", true},
+		{"127.0.0.1", true},
+		{"10.0.0.1", true},
+		{"172.16.5.4", true},
+		{"192.168.1.1", true},
+		{"100.64.0.1", true},
+		{"100.127.255.254", true},
+		{"0.0.0.0", true},
+		{"198.18.0.1", true},
+		{"240.0.0.1", true},
+		{"224.0.0.1", true},
+		{"::1", true},
+		{"::", true},
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"::ffff:10.0.0.1", true},
+		{"::ffff:169.254.169.254", true},
+		{"64:ff9b::a00:1", true},
+		{"64:ff9b::7f00:1", true},
+		{"64:ff9b:1::1", true},
+		{"2002:a00:1::1", true},
+		{"2002:a9fe:a9fe::", true},
+
+		{"8.8.8.8", false},
+		{"100.128.0.1", false},
+		{"::ffff:8.8.8.8", false},
+		{"64:ff9b::808:808", false},
+		{"2002:808:808::1", false},
+		{"2606:4700:4700::1111", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isBlockedIP(netip.MustParseAddr(tt.ip)); got != tt.want {
+				t.Errorf("isBlockedIP(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+// guardedClient возвращает клиент, которому разрешено подключаться к тестовому серверу на 127.0.0.1
+func guardedClient(maxRedirects int) *http.Client {
+	guard := &outboundGuard{
+		trusted:      []string{"127.0.0.1"},
+		maxRedirects: maxRedirects,
+		dialer:       &net.Dialer{Timeout: time.Second},
+	}
+
+	return guard.client(5 * time.Second)
+}
+
+func TestGuardBlocksRedirectToLoopback(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	// Сам тестовый сервер доверенный, иначе ошибка была бы и без редиректа
+	direct, _ := http.NewRequestWithContext(withUpstream(context.Background(), "ingest"), http.MethodGet, target.URL, nil)
+	resp, err := guardedClient(3).Do(direct)
+	if err != nil {
+		t.Fatalf("trusted host was blocked: %v", err)
+	}
+	resp.Body.Close()
+
+	_, port, _ := net.SplitHostPort(target.Listener.Addr().String())
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://localhost:"+port+"/", http.StatusFound)
+	}))
+	defer redirect.Close()
+
+	req, _ := http.NewRequestWithContext(withUpstream(context.Background(), "ingest"), http.MethodGet, redirect.URL, nil)
+	resp, err = guardedClient(3).Do(req)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("redirect to loopback was followed")
+	}
+	if !errors.Is(err, ErrBlockedDestination) {
+		t.Errorf("expected ErrBlockedDestination, got %v", err)
+	}
+}
+
+func TestGuardLimitsRedirects(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, server.URL+"/loop", http.StatusFound)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequestWithContext(withUpstream(context.Background(), "ingest"), http.MethodGet, server.URL, nil)
+	resp, err := guardedClient(2).Do(req)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("redirect loop was followed")
+	}
+	if !errors.Is(err, ErrBlockedDestination) {
+		t.Errorf("expected ErrBlockedDestination, got %v", err)
+	}
+}
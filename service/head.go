@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+
+	"resizer/api/model"
+	"resizer/converter/image"
+	"resizer/shared/log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.uber.org/zap"
+)
+
+// HeadProcess отдает метаданные варианта только из метаданных объектов, без скачивания и преобразования.
+// Размер и размеры еще не закешированного варианта неизвестны, поэтому в ответе их нет
+func (i *ImageService) HeadProcess(ctx context.Context, params model.ImageRequest, cond model.Preconditions) (*model.ImageResponse, error) {
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	head, err := i.headFromS3(ctx, params)
+	if err != nil {
+		logger.Error("Error getting image metadata from S3", zap.Error(err))
+		return nil, err
+	}
+
+	contentType := aws.StringValue(head.ContentType)
+	etag := variantETag(contentType, aws.StringValue(head.ETag), params)
+	lastModified := aws.TimeValue(head.LastModified)
+	if cond.NotModified(etag, lastModified) {
+		return &model.ImageResponse{ETag: etag, LastModified: lastModified, NotModified: true}, nil
+	}
+
+	if contentType == "image/svg+xml" {
+		return &model.ImageResponse{
+			ContentType:   contentType,
+			ContentLength: aws.Int64Value(head.ContentLength),
+			ETag:          etag,
+			LastModified:  lastModified,
+		}, nil
+	}
+
+	if etag != "" {
+		key := variantKey(params, etag)
+		variant, err := i.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: &i.config.S3Bucket, Key: &key})
+		if err == nil {
+			width, height := metadataSize(variant.Metadata)
+			return &model.ImageResponse{
+				ContentType:   params.Type.MIME(),
+				ContentLength: aws.Int64Value(variant.ContentLength),
+				ETag:          etag,
+				LastModified:  lastModified,
+				Width:         width,
+				Height:        height,
+			}, nil
+		}
+		if !isNotFoundError(err) {
+			logger.Warn("Error getting image variant metadata from S3", zap.Error(err))
+		}
+	}
+
+	return &model.ImageResponse{
+		ContentType:  params.Type.MIME(),
+		ETag:         etag,
+		LastModified: lastModified,
+	}, nil
+}
+
+// HeadOriginal отдает метаданные оригинала без скачивания тела
+func (i *ImageService) HeadOriginal(ctx context.Context, entity, file string, cond model.Preconditions) (*model.ImageResponse, error) {
+	return i.headObject(ctx, fmt.Sprintf("%s/%s", entity, file), cond)
+}
+
+// HeadProxy отдает метаданные проксируемого изображения. Если его нет в S3, оно загружается и кешируется
+func (i *ImageService) HeadProxy(ctx context.Context, serviceType model.ServiceName, rawPath string, cond model.Preconditions) (*model.ImageResponse, error) {
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	key := path.Join("proxy", serviceType.String(), rawPath)
+	info, err := i.headObject(ctx, key, cond)
+	if err == nil {
+		info.Provenance.Cache = model.CacheHit
+		return info, nil
+	}
+	if !isNotFoundError(err) {
+		logger.Warn("ошибка при получении метаданных из S3", zap.Error(err), zap.String("key", key))
+	}
+
+	resp, err := i.ProxyImage(ctx, serviceType, rawPath, cond, model.Range{})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return &model.ImageResponse{NotModified: true, Provenance: resp.Provenance}, nil
+	}
+
+	return proxyToImageResponse(resp), nil
+}
+
+// headObject строит ответ по HEAD объекта; размеры отдаются, только если сохранены в метаданных
+func (i *ImageService) headObject(ctx context.Context, key string, cond model.Preconditions) (*model.ImageResponse, error) {
+	input := &s3.HeadObjectInput{Bucket: &i.config.S3Bucket, Key: aws.String(key)}
+	if cond.IfNoneMatch != "" {
+		input.IfNoneMatch = aws.String(cond.IfNoneMatch)
+	} else if !cond.IfModifiedSince.IsZero() {
+		input.IfModifiedSince = aws.Time(cond.IfModifiedSince)
+	}
+
+	// Как и в Original, заголовок клиента не выдается за ETag объекта
+	head, err := i.s3.HeadObjectWithContext(ctx, input)
+	if isNotModifiedError(err) {
+		return &model.ImageResponse{NotModified: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := metadataSize(head.Metadata)
+
+	return &model.ImageResponse{
+		ContentType:   aws.StringValue(head.ContentType),
+		ContentLength: aws.Int64Value(head.ContentLength),
+		ETag:          aws.StringValue(head.ETag),
+		LastModified:  aws.TimeValue(head.LastModified),
+		Width:         width,
+		Height:        height,
+		Provenance:    provenanceFromMetadata(head.Metadata),
+	}, nil
+}
+
+func proxyToImageResponse(resp *ProxyResponse) *model.ImageResponse {
+	size, _ := image.ReadHeader(resp.rawBytes)
+
+	return &model.ImageResponse{
+		ContentType:   resp.contentType,
+		ContentLength: int64(len(resp.rawBytes)),
+		ETag:          resp.ETag,
+		LastModified:  resp.LastModified,
+		Width:         size.Width,
+		Height:        size.Height,
+		Provenance:    resp.Provenance,
+	}
+}
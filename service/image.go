@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +18,7 @@ import (
 	"resizer/api/model"
 	"resizer/config"
 	"resizer/converter/image"
+	"resizer/converter/image/format"
 	"resizer/shared/log"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -25,7 +27,23 @@ import (
 	"go.uber.org/zap"
 )
 
-var ErrNotFound = errors.New("object not found in S3")
+var (
+	ErrNotFound            = errors.New("object not found in S3")
+	ErrRangeNotSatisfiable = errors.New("requested range not satisfiable")
+)
+
+// RangeNotSatisfiableError несет Content-Range с размером объекта, который требуется в ответе 416
+type RangeNotSatisfiableError struct {
+	ContentRange string
+}
+
+func (e *RangeNotSatisfiableError) Error() string {
+	return ErrRangeNotSatisfiable.Error()
+}
+
+func (e *RangeNotSatisfiableError) Unwrap() error {
+	return ErrRangeNotSatisfiable
+}
 
 type ImageService struct {
 	config *config.Config
@@ -33,6 +51,9 @@ type ImageService struct {
 	s3 *s3.S3
 
 	strategy *image.Strategy
+	limits   image.Limits
+	metadata format.Metadata
+	pool     *image.Pool
 
 	logger *zap.Logger
 
@@ -42,62 +63,311 @@ type ImageService struct {
 
 	// semaphore для ограничения количества одновременных операций кеширования
 	cacheSemaphore chan struct{}
+	// cacheWG отслеживает фоновые сохранения в S3, чтобы CLI дождался их перед выходом
+	cacheWG sync.WaitGroup
+	// revalidating - ключи, которые сейчас перепроверяются в апстриме
+	revalidating sync.Map
+
+	jobs *jobStore
+
+	// client выполняет все исходящие запросы к апстримам через guard
+	guard  *outboundGuard
+	client *http.Client
 }
 
-func NewImageService(s3 *s3.S3, c *config.Config, strategy *image.Strategy, logger *zap.Logger) *ImageService {
+func NewImageService(s3 *s3.S3, c *config.Config, strategy *image.Strategy, pool *image.Pool, logger *zap.Logger) *ImageService {
 	service := &ImageService{
-		s3:             s3,
-		config:         c,
-		strategy:       strategy,
+		s3:       s3,
+		config:   c,
+		strategy: strategy,
+		pool:     pool,
+		limits: image.Limits{
+			MaxInputPixels:  int(c.MaxInputMegapixels * 1_000_000),
+			MaxOutputWidth:  c.MaxOutputWidth,
+			MaxOutputHeight: c.MaxOutputHeight,
+			MaxOutputPixels: int(c.MaxOutputMegapixels * 1_000_000),
+		},
+		metadata: format.Metadata{
+			Strip:         c.StripMetadata,
+			KeepCopyright: c.KeepCopyright,
+			SRGB:          c.ConvertToSRGB,
+			EmbedProfile:  c.EmbedICCProfile,
+		},
 		logger:         logger,
 		cacheSemaphore: make(chan struct{}, 50), // Ограничиваем до 50 одновременных операций кеширования
+		jobs:           newJobStore(),
+		guard:          newOutboundGuard(c),
 	}
+	service.client = service.guard.client(fetchTimeout)
 	service.initFailedURLsFile()
 	return service
 }
 
-func (i *ImageService) Process(ctx context.Context, params model.ImageRequest) (*model.ImageResponse, error) {
+// Limits возвращает ограничения на размеры входных и выходных изображений
+func (i *ImageService) Limits() image.Limits {
+	return i.limits
+}
+
+func (i *ImageService) Process(ctx context.Context, params model.ImageRequest, cond model.Preconditions) (*model.ImageResponse, error) {
 	logger := log.LoggerWithTrace(ctx, i.logger)
 
+	// Сначала проверяем валидаторы через HEAD, чтобы не скачивать оригинал ради 304
+	head, err := i.headFromS3(ctx, params)
+	if err != nil {
+		logger.Error("Error getting image metadata from S3", zap.Error(err))
+		return nil, err
+	}
+
+	etag := variantETag(aws.StringValue(head.ContentType), aws.StringValue(head.ETag), params)
+	lastModified := aws.TimeValue(head.LastModified)
+	if cond.NotModified(etag, lastModified) {
+		logger.Debug("Image not modified", zap.String("etag", etag))
+		return &model.ImageResponse{
+			Type:         params.Type.String(),
+			ETag:         etag,
+			LastModified: lastModified,
+			NotModified:  true,
+		}, nil
+	}
+
+	// Готовый вариант из кеша отдается без декодирования
+	variant, err := i.getVariant(ctx, params, aws.StringValue(head.ContentType), etag, lastModified)
+	if err == nil {
+		logger.Debug("Image variant fetched from S3", zap.String("etag", etag))
+		return variant, nil
+	}
+	if !errors.Is(err, errNoVariant) && !isNotFoundError(err) {
+		logger.Warn("Error getting image variant from S3", zap.Error(err))
+	}
+
+	// Формат может отсутствовать в сборке libvips, проверяем до скачивания оригинала
+	if err = i.checkOutputType(aws.StringValue(head.ContentType), params); err != nil {
+		return nil, err
+	}
+
 	result, err := i.getFromS3(ctx, params)
 	if err != nil {
 		logger.Error("Error getting image from S3", zap.Error(err))
 		return nil, err
 	}
 
-	if *result.ContentType == "image/svg+xml" {
+	etag = variantETag(aws.StringValue(result.ContentType), aws.StringValue(result.ETag), params)
+	lastModified = aws.TimeValue(result.LastModified)
+
+	// Оригинал читается целиком до занятия слота пула, чтобы медленное чтение из S3 не держало слот libvips
+	source, err := io.ReadAll(result.Body)
+	result.Body.Close()
+	if err != nil {
+		logger.Error("Error reading image from S3", zap.Error(err))
+		return nil, err
+	}
+
+	return i.render(ctx, params, aws.StringValue(result.ContentType), source, etag, lastModified)
+}
+
+// ProcessProxy преобразует проксируемое изображение. Кеш прокси лежит по ключу proxy/<service>/<path>,
+// поэтому закешированное изображение обрабатывается как оригинал сущности proxy/<service>
+func (i *ImageService) ProcessProxy(ctx context.Context, serviceType model.ServiceName, rawPath string, params model.ImageRequest, cond model.Preconditions) (*model.ImageResponse, error) {
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	params.Entity = path.Join("proxy", serviceType.String())
+	params.File = rawPath
+
+	response, err := i.Process(ctx, params, cond)
+	if !isNotFoundError(err) {
+		return response, err
+	}
+
+	// Изображения еще нет в кеше: загружаем его через прокси и преобразуем загруженные байты,
+	// не дожидаясь асинхронного сохранения в S3
+	logger.Debug("proxied image is not cached yet", zap.String("path", rawPath))
+	// Любой ответ апстрима, кроме 200, приходит ошибкой; отсутствие изображения отдается как 404
+	resp, err := i.ProxyImage(ctx, serviceType, rawPath, model.Preconditions{}, model.Range{})
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusGone) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, rawPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK || !i.isValidImageResponse(resp) {
+		return nil, fmt.Errorf("upstream returned no image for %s, status %d", rawPath, resp.StatusCode)
+	}
+
+	if err = i.checkOutputType(resp.contentType, params); err != nil {
+		return nil, err
+	}
+
+	etag := variantETag(resp.contentType, resp.ETag, params)
+	return i.render(ctx, params, resp.contentType, resp.rawBytes, etag, resp.LastModified)
+}
+
+// checkOutputType проверяет, что libvips умеет сохранять запрошенный формат. SVG отдается как есть
+func (i *ImageService) checkOutputType(sourceContentType string, params model.ImageRequest) error {
+	if sourceContentType == "image/svg+xml" || i.strategy.CanSave(params.Type) {
+		return nil
+	}
+
+	return &image.LimitError{
+		Code:    image.CodeUnsupported,
+		Message: fmt.Sprintf("%s output is not supported by libvips", params.Type.String()),
+	}
+}
+
+// render декодирует исходное изображение, преобразует его и кеширует полученный вариант
+func (i *ImageService) render(ctx context.Context, params model.ImageRequest, sourceContentType string, source []byte, etag string, lastModified time.Time) (*model.ImageResponse, error) {
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	if sourceContentType == "image/svg+xml" {
 		return &model.ImageResponse{
-			Body:               result.Body,
-			ContentLength:      *result.ContentLength,
-			ContentDisposition: fmt.Sprintf("inline; filename=%s.%s", params.File, result.ContentType),
+			Body:               bytes.NewReader(source),
+			ContentLength:      int64(len(source)),
+			ContentDisposition: fmt.Sprintf("inline; filename=%s.%s", path.Base(params.File), sourceContentType),
 			Type:               params.Type.String(),
+			ETag:               etag,
+			LastModified:       lastModified,
 		}, nil
 	}
 
-	customImage := image.NewCustomImage(i.strategy.Apply(params.Type))
-	if err = customImage.Decode(result.Body); err != nil {
-		logger.Error("Error decoding format type", zap.Error(err))
+	var (
+		img           io.Reader
+		contentLength int64
+	)
+
+	// Декодирование и кодирование выполняются в пуле, чтобы ограничить нагрузку на libvips
+	err := i.pool.Do(ctx, func() error {
+		customImage := image.NewCustomImage(i.strategy.Apply(params.Type), i.limits, i.metadata)
+		if err := customImage.Decode(bytes.NewReader(source)); err != nil {
+			logger.Error("Error decoding format type", zap.Error(err))
+			return err
+		}
+
+		width, height := customImage.ScaleForDPR(params.Width, params.Height, params.DPR)
+		if err := customImage.CheckOutput(width, height); err != nil {
+			logger.Warn("Requested size exceeds limits", zap.Error(err))
+			return err
+		}
+
+		var err error
+		// Анимация сохраняется только при изменении ширины: обрезка по высоте работает с первым кадром
+		if customImage.IsAnimated() && customImage.CanAnimate() && !params.FirstFrame && height == 0 {
+			img, contentLength, err = customImage.EncodeAnimated(ctx, width, params.Quality, params.Options)
+		} else {
+			customImage.Transform(image.WithSize(width, height, params.Fit))
+			img, contentLength, err = customImage.Encode(ctx, params.Quality, params.Options)
+		}
+		if err != nil {
+			logger.Error("Error encoding format type", zap.Error(err))
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	customImage.Transform(image.WithWidth(params.Width))
-
-	img, contentLength, err := customImage.Encode(ctx, params.Quality)
+	buf, err := io.ReadAll(img)
 	if err != nil {
-		logger.Error("Error encoding format type", zap.Error(err))
 		return nil, err
 	}
 
+	size, _ := image.ReadHeader(buf)
+	i.cacheVariant(params, etag, buf)
+
 	logger.Debug(fmt.Sprintf("Image %s converted to %s, quality: %f, width: %d", params.File, params.Type, params.Quality, params.Width))
 
 	return &model.ImageResponse{
-		Body:               img,
+		Body:               bytes.NewReader(buf),
+		ContentType:        params.Type.MIME(),
 		ContentLength:      contentLength,
-		ContentDisposition: fmt.Sprintf("inline; filename=%s.%s", params.File, params.Type),
+		ContentDisposition: fmt.Sprintf("inline; filename=%s.%s", path.Base(params.File), params.Type),
 		Type:               params.Type.String(),
+		ETag:               etag,
+		LastModified:       lastModified,
+		Width:              size.Width,
+		Height:             size.Height,
 	}, nil
 }
 
+// variantETag строит ETag варианта из ETag оригинала и параметров преобразования
+func variantETag(contentType, sourceETag string, params model.ImageRequest) string {
+	if sourceETag == "" {
+		return ""
+	}
+
+	// SVG отдается без преобразования, поэтому ETag совпадает с оригиналом
+	if contentType == "image/svg+xml" {
+		return sourceETag
+	}
+
+	return fmt.Sprintf(`"%s-%s"`, strings.Trim(sourceETag, `"`), params.VariantKey())
+}
+
+// Original отдает оригинал из S3 без преобразований с поддержкой условных запросов и Range
+func (i *ImageService) Original(ctx context.Context, entity, file string, cond model.Preconditions, rng model.Range) (*model.ImageResponse, error) {
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	fileKey := fmt.Sprintf("%s/%s", entity, file)
+	logger = logger.With(zap.String("fileKey", fileKey))
+
+	input := &s3.GetObjectInput{Bucket: &i.config.S3Bucket, Key: &fileKey}
+	applyConditions(input, cond, rng)
+
+	// Ответ S3 на 304 не содержит ETag, а заголовок клиента может быть списком или *, поэтому ETag не отдается
+	result, err := i.s3.GetObjectWithContext(ctx, input)
+	if isNotModifiedError(err) {
+		return &model.ImageResponse{NotModified: true}, nil
+	}
+	if isRangeNotSatisfiableError(err) {
+		return nil, &RangeNotSatisfiableError{ContentRange: i.unsatisfiedRange(ctx, i.config.S3Bucket, fileKey)}
+	}
+	if err != nil {
+		logger.Error("Error getting original from S3", zap.Error(err))
+		return nil, err
+	}
+
+	return &model.ImageResponse{
+		Body:               result.Body,
+		ContentType:        aws.StringValue(result.ContentType),
+		ContentLength:      aws.Int64Value(result.ContentLength),
+		ContentDisposition: fmt.Sprintf("inline; filename=%s", file),
+		ContentRange:       aws.StringValue(result.ContentRange),
+		ETag:               aws.StringValue(result.ETag),
+		LastModified:       aws.TimeValue(result.LastModified),
+	}, nil
+}
+
+// applyConditions переносит условные заголовки и Range клиента в запрос к S3
+func applyConditions(input *s3.GetObjectInput, cond model.Preconditions, rng model.Range) {
+	if cond.IfNoneMatch != "" {
+		input.IfNoneMatch = aws.String(cond.IfNoneMatch)
+	} else if !cond.IfModifiedSince.IsZero() {
+		input.IfModifiedSince = aws.Time(cond.IfModifiedSince)
+	}
+
+	if rng.IsSet() {
+		input.Range = aws.String(rng.String())
+	}
+}
+
+// unsatisfiedRange возвращает Content-Range вида bytes */<size> для ответа 416.
+// Ошибка S3 размер не содержит, поэтому он берется из HEAD; если HEAD не удался, заголовок не отдается
+func (i *ImageService) unsatisfiedRange(ctx context.Context, bucket, key string) string {
+	head, err := i.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("bytes */%d", aws.Int64Value(head.ContentLength))
+}
+
+func (i *ImageService) headFromS3(ctx context.Context, params model.ImageRequest) (*s3.HeadObjectOutput, error) {
+	fileKey := fmt.Sprintf("%s/%s", params.Entity, params.File)
+
+	return i.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: &i.config.S3Bucket, Key: &fileKey})
+}
+
 func (i *ImageService) getFromS3(ctx context.Context, params model.ImageRequest) (*s3.GetObjectOutput, error) {
 	logger := log.LoggerWithTrace(ctx, i.logger)
 
@@ -116,25 +386,63 @@ func (i *ImageService) getFromS3(ctx context.Context, params model.ImageRequest)
 }
 
 type ProxyResponse struct {
-	Body        io.ReadCloser
-	Headers     http.Header
-	StatusCode  int
-	rawBytes    []byte
-	contentType string
+	Body         io.ReadCloser
+	Headers      http.Header
+	StatusCode   int
+	ETag         string
+	LastModified time.Time
+	rawBytes     []byte
+	contentType  string
+
+	// Provenance - происхождение ответа, UpstreamETag и UpstreamLastModified - валидаторы, выданные апстримом
+	Provenance           model.Provenance
+	UpstreamETag         string
+	UpstreamLastModified time.Time
+
+	// metadata - пользовательские метаданные объекта из S3
+	metadata map[string]*string
 }
 
-func (i *ImageService) ProxyImage(ctx context.Context, serviceType model.ServiceName, rawPath string) (*ProxyResponse, error) {
+// proxyKey возвращает ключ кеша прокси. path.Join очищает путь, поэтому ключ дополнительно
+// сверяется с пространством кеша сервиса: записать или удалить оригинал через прокси нельзя
+func proxyKey(serviceType model.ServiceName, rawPath string) (string, error) {
+	if err := model.ValidateProxyPath(rawPath); err != nil {
+		return "", err
+	}
+
+	serviceRoot := path.Join("proxy", serviceType.String())
+	key := path.Join(serviceRoot, rawPath)
+	if !strings.HasPrefix(key, serviceRoot+"/") {
+		return "", &image.LimitError{Code: model.CodeInvalidKey, Message: fmt.Sprintf("path %q escapes the proxy cache", rawPath)}
+	}
+
+	return key, nil
+}
+
+func (i *ImageService) ProxyImage(ctx context.Context, serviceType model.ServiceName, rawPath string, cond model.Preconditions, rng model.Range) (*ProxyResponse, error) {
 	logger := log.LoggerWithTrace(ctx, i.logger)
 
-	key := path.Join("proxy", serviceType.String(), rawPath)
+	key, err := proxyKey(serviceType, rawPath)
+	if err != nil {
+		return nil, err
+	}
 	bucket := i.config.S3Bucket
 	url := serviceType.ToProxyURL(i.config.TMDBImageProxy) + rawPath
 
 	// 1. Пробуем получить из S3
 	logger.Debug("проверяем S3 кеш", zap.String("key", key))
-	imageData, err := i.tryGetFromS3(ctx, bucket, key)
+	imageData, err := i.tryGetFromS3(ctx, bucket, key, cond, rng)
+	if err == nil && imageData != nil && imageData.StatusCode == http.StatusNotModified {
+		logger.Debug("изображение в S3 не изменилось", zap.String("key", key))
+		return imageData, nil
+	}
 	if err == nil && imageData != nil {
 		logger.Info("изображение получено из S3", zap.String("key", key))
+		// Устаревшая копия отдается сразу, а апстрим перепроверяется в фоне
+		if i.isStale(imageData) {
+			logger.Debug("изображение в S3 устарело, перепроверяем в фоне", zap.String("key", key))
+			i.revalidateInBackground(serviceType, rawPath, imageData)
+		}
 		return imageData, nil
 	}
 
@@ -164,25 +472,65 @@ func (i *ImageService) ProxyImage(ctx context.Context, serviceType model.Service
 
 	// 3. Кешируем результат в S3 (асинхронно), если это валидное изображение
 	if i.isValidImageResponse(imageData) {
-		go i.cacheInS3(bucket, key, imageData, url)
+		i.cacheInBackground(bucket, key, imageData, url)
 	} else {
 		logger.Warn("не кешируем невалидный ответ", zap.String("url", url), zap.String("content_type", imageData.contentType))
 	}
 
 	logger.Info("изображение получено от внешнего сервиса", zap.String("url", url))
-	return imageData, nil
+	return sliceRange(imageData, rng), nil
 }
 
-// tryGetFromS3 пытается получить изображение из S3
-func (i *ImageService) tryGetFromS3(ctx context.Context, bucket, key string) (*ProxyResponse, error) {
+// sliceRange вырезает запрошенный диапазон из полностью загруженного ответа
+func sliceRange(resp *ProxyResponse, rng model.Range) *ProxyResponse {
+	if !rng.IsSet() {
+		return resp
+	}
+
+	// Копируем ответ: оригинал может одновременно кешироваться в S3
+	partial := *resp
+	partial.Headers = resp.Headers.Clone()
+
+	size := int64(len(resp.rawBytes))
+	start, end, ok := rng.Bounds(size)
+	if !ok {
+		partial.StatusCode = http.StatusRequestedRangeNotSatisfiable
+		partial.Body = nil
+		partial.Headers.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		return &partial
+	}
+
+	partial.StatusCode = http.StatusPartialContent
+	partial.Body = io.NopCloser(bytes.NewReader(resp.rawBytes[start : end+1]))
+	partial.Headers.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	partial.Headers.Set("Content-Length", fmt.Sprint(end-start+1))
+	return &partial
+}
+
+// tryGetFromS3 пытается получить изображение из S3.
+// Условный GET позволяет ответить 304, не скачивая тело объекта, а Range передается в S3 как есть
+func (i *ImageService) tryGetFromS3(ctx context.Context, bucket, key string, cond model.Preconditions, rng model.Range) (*ProxyResponse, error) {
 	// Создаем context с таймаутом для S3
 	s3Ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	getOut, err := i.s3.GetObjectWithContext(s3Ctx, &s3.GetObjectInput{
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
-	})
+	}
+	applyConditions(input, cond, rng)
+
+	getOut, err := i.s3.GetObjectWithContext(s3Ctx, input)
+	if isNotModifiedError(err) {
+		return &ProxyResponse{StatusCode: http.StatusNotModified, Provenance: model.Provenance{Cache: model.CacheHit}}, nil
+	}
+	if isRangeNotSatisfiableError(err) {
+		headers := http.Header{}
+		if contentRange := i.unsatisfiedRange(s3Ctx, bucket, key); contentRange != "" {
+			headers.Set("Content-Range", contentRange)
+		}
+		return &ProxyResponse{StatusCode: http.StatusRequestedRangeNotSatisfiable, Headers: headers, Provenance: model.Provenance{Cache: model.CacheHit}}, nil
+	}
 
 	if err != nil {
 		return nil, err
@@ -215,71 +563,62 @@ func (i *ImageService) tryGetFromS3(ctx context.Context, bucket, key string) (*P
 		headers.Set("Content-Length", fmt.Sprint(*getOut.ContentLength))
 	}
 
+	statusCode := http.StatusOK
+	sniffBytes := bodyBytes
+	if getOut.ContentRange != nil {
+		statusCode = http.StatusPartialContent
+		headers.Set("Content-Range", aws.StringValue(getOut.ContentRange))
+		// Сигнатуру HTML можно проверить только по началу объекта
+		if !strings.HasPrefix(aws.StringValue(getOut.ContentRange), "bytes 0-") {
+			sniffBytes = nil
+		}
+	}
+
 	// Проверяем, что это не HTML ошибка
-	if i.isHTMLContent(contentType, bodyBytes) {
+	if i.isHTMLContent(contentType, sniffBytes) {
 		return nil, errors.New("object is HTML page")
 	}
 
-	return &ProxyResponse{
-		Body:        io.NopCloser(bytes.NewReader(bodyBytes)),
-		Headers:     headers,
-		StatusCode:  http.StatusOK,
-		rawBytes:    bodyBytes,
-		contentType: contentType,
-	}, nil
+	resp := &ProxyResponse{
+		Body:         io.NopCloser(bytes.NewReader(bodyBytes)),
+		Headers:      headers,
+		StatusCode:   statusCode,
+		ETag:         aws.StringValue(getOut.ETag),
+		LastModified: aws.TimeValue(getOut.LastModified),
+		rawBytes:     bodyBytes,
+		contentType:  contentType,
+	}
+	resp.readProvenance(getOut.Metadata)
+
+	return resp, nil
 }
 
 // fetchFromExternalService получает изображение от внешнего сервиса
 func (i *ImageService) fetchFromExternalService(ctx context.Context, url string, serviceType model.ServiceName, rawPath string) (*ProxyResponse, error) {
-	// Создаем HTTP клиент с таймаутом для предотвращения зависания
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
+	resp, err := i.fetchURL(ctx, serviceType.String(), url, model.Preconditions{})
 
-	if res.StatusCode != http.StatusOK {
-		res.Body.Close()
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) {
 		// Записываем неуспешную ссылку в файл в формате /service-type/path
 		failedPath := fmt.Sprintf("/%s/%s", serviceType.String(), rawPath)
-		i.logFailedURL(failedPath, res.StatusCode)
-		// Возвращаем error вместо пустого response, чтобы избежать nil pointers
-		return nil, fmt.Errorf("external service returned status %d for %s", res.StatusCode, url)
-	}
-
-	bodyBytes, err := ioutil.ReadAll(res.Body)
-	res.Body.Close()
-	if err != nil {
-		return nil, err
+		i.logFailedURL(failedPath, statusErr.StatusCode)
 	}
 
-	// Проверяем, что получили данные
-	if len(bodyBytes) == 0 {
-		return nil, fmt.Errorf("external service returned empty body for %s", url)
-	}
+	return resp, err
+}
 
-	headers := make(http.Header)
-	contentType := res.Header.Get("Content-Type")
-	if contentType != "" {
-		headers.Set("Content-Type", contentType)
-	}
-	headers.Set("Content-Length", fmt.Sprint(len(bodyBytes)))
+// cacheInBackground запускает cacheInS3 в отдельной горутине
+func (i *ImageService) cacheInBackground(bucket, key string, resp *ProxyResponse, url string) {
+	i.cacheWG.Add(1)
+	go func() {
+		defer i.cacheWG.Done()
+		i.cacheInS3(bucket, key, resp, url)
+	}()
+}
 
-	return &ProxyResponse{
-		Body:        io.NopCloser(bytes.NewReader(bodyBytes)),
-		Headers:     headers,
-		StatusCode:  http.StatusOK,
-		rawBytes:    bodyBytes,
-		contentType: contentType,
-	}, nil
+// Flush ожидает завершения фоновых сохранений в S3
+func (i *ImageService) Flush() {
+	i.cacheWG.Wait()
 }
 
 // cacheInS3 асинхронно кеширует изображение в S3
@@ -313,12 +652,21 @@ func (i *ImageService) cacheInS3(bucket, key string, resp *ProxyResponse, url st
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// Размеры сохраняются в метаданных, чтобы HEAD мог отдать их без скачивания тела,
+	// а происхождение - для перепроверки и отладки
+	metadata := provenanceMetadata(resp, url, time.Now())
+	if size, err := image.ReadHeader(resp.rawBytes); err == nil {
+		metadata[metaWidth] = aws.String(strconv.Itoa(size.Width))
+		metadata[metaHeight] = aws.String(strconv.Itoa(size.Height))
+	}
+
 	uploader := s3manager.NewUploaderWithClient(i.s3)
 	_, err := uploader.UploadWithContext(ctx, &s3manager.UploadInput{
 		Bucket:      aws.String(bucket),
 		Key:         aws.String(key),
 		Body:        bytes.NewReader(resp.rawBytes),
 		ContentType: aws.String(contentType),
+		Metadata:    metadata,
 	})
 
 	if err != nil {
@@ -341,6 +689,29 @@ func isNotFoundError(err error) bool {
 	return false
 }
 
+// isConditionFailedError сообщает, что условная запись не выполнена: объект уже есть (412)
+// или его одновременно записывает другой запрос (409)
+func isConditionFailedError(err error) bool {
+	if aerr, ok := err.(s3.RequestFailure); ok {
+		return aerr.StatusCode() == http.StatusPreconditionFailed || aerr.StatusCode() == http.StatusConflict
+	}
+	return false
+}
+
+func isRangeNotSatisfiableError(err error) bool {
+	if aerr, ok := err.(s3.RequestFailure); ok && aerr.StatusCode() == http.StatusRequestedRangeNotSatisfiable {
+		return true
+	}
+	return false
+}
+
+func isNotModifiedError(err error) bool {
+	if aerr, ok := err.(s3.RequestFailure); ok && aerr.StatusCode() == http.StatusNotModified {
+		return true
+	}
+	return false
+}
+
 // deleteFromS3 удаляет объект из S3
 func (i *ImageService) deleteFromS3(bucket, key string) {
 	logger := i.logger
@@ -386,7 +757,8 @@ func (i *ImageService) isValidImageResponse(resp *ProxyResponse) bool {
 
 	// Проверяем Content-Type на валидные типы изображений
 	contentType := strings.ToLower(resp.contentType)
-	validTypes := []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/svg+xml", "image/avif"}
+	validTypes := []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/svg+xml", "image/avif",
+		"image/heic", "image/heif", "image/jxl"}
 	for _, validType := range validTypes {
 		if strings.Contains(contentType, validType) {
 			return true
@@ -401,31 +773,13 @@ func (i *ImageService) isValidImageResponse(resp *ProxyResponse) bool {
 	return false
 }
 
-// isValidImageBySignature проверяет сигнатуру файла
+// isValidImageBySignature проверяет сигнатуру файла: JPEG, PNG, GIF, WebP, AVIF, HEIC/HEIF и JPEG XL
 func (i *ImageService) isValidImageBySignature(data []byte) bool {
 	if len(data) < 8 {
 		return false
 	}
 
-	// JPEG
-	if data[0] == 0xFF && data[1] == 0xD8 {
-		return true
-	}
-	// PNG
-	if data[0] == 0x89 && data[1] == 0x50 && data[2] == 0x4E && data[3] == 0x47 {
-		return true
-	}
-	// GIF
-	if data[0] == 0x47 && data[1] == 0x49 && data[2] == 0x46 {
-		return true
-	}
-	// WebP
-	if len(data) >= 12 && data[0] == 0x52 && data[1] == 0x49 && data[2] == 0x46 && data[3] == 0x46 &&
-		data[8] == 0x57 && data[9] == 0x45 && data[10] == 0x42 && data[11] == 0x50 {
-		return true
-	}
-
-	return false
+	return image.DetectFormat(data) != ""
 }
 
 func min(a, b int) int {
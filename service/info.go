@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+
+	"resizer/api/model"
+	"resizer/converter/image"
+	"resizer/shared/log"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/h2non/bimg"
+	"go.uber.org/zap"
+)
+
+// OriginalInfo возвращает описание оригинала из S3
+func (i *ImageService) OriginalInfo(ctx context.Context, entity, file string) (*model.ImageInfo, error) {
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	key := fmt.Sprintf("%s/%s", entity, file)
+	result, err := i.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: &i.config.S3Bucket, Key: &key})
+	if err != nil {
+		logger.Error("Error getting original from S3", zap.Error(err), zap.String("fileKey", key))
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	buf, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return inspect(key, buf)
+}
+
+// ProxyInfo возвращает описание проксируемого изображения из кеша или внешнего сервиса
+func (i *ImageService) ProxyInfo(ctx context.Context, serviceType model.ServiceName, rawPath string) (*model.ImageInfo, error) {
+	resp, err := i.ProxyImage(ctx, serviceType, rawPath, model.Preconditions{}, model.Range{})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected proxy response status")
+	}
+
+	return inspect(path.Join("proxy", serviceType.String(), rawPath), resp.rawBytes)
+}
+
+func inspect(key string, buf []byte) (*model.ImageInfo, error) {
+	info, err := image.Inspect(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.ImageInfo{
+		Key:         key,
+		Format:      info.Format,
+		Width:       info.Width,
+		Height:      info.Height,
+		Orientation: info.Orientation,
+		ColorSpace:  info.ColorSpace,
+		HasAlpha:    info.HasAlpha,
+		Size:        int64(len(buf)),
+		Frames:      info.Frames,
+	}, nil
+}
+
+// Info возвращает версию libvips и форматы, доступные в текущей сборке
+func (i *ImageService) Info() model.ServiceInfo {
+	formats := make(map[string]model.FormatSupport)
+	for name, capability := range image.Capabilities() {
+		formats[name] = model.FormatSupport{Load: capability.Load, Save: capability.Save}
+	}
+
+	return model.ServiceInfo{VipsVersion: bimg.VipsVersion, Formats: formats}
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"resizer/api/model"
+	"resizer/shared/log"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// ingestUpstream - имя апстрима загрузки по URL в FETCH_ALLOWED_HOSTS
+	ingestUpstream = "ingest"
+
+	ingestConcurrency = 4
+	ingestTimeout     = 2 * time.Minute
+)
+
+var ErrUpstream = errors.New("upstream fetch failed")
+
+// Ingest загружает изображение по URL и сохраняет его как оригинал. Ответ проходит те же проверки,
+// что и у прокси (таймаут, размер, HTML, сигнатура), а затем те же, что и при загрузке.
+// Как и Upload, сообщает, был ли оригинал создан
+func (i *ImageService) Ingest(ctx context.Context, item model.IngestItem) (*model.ImageInfo, bool, error) {
+	logger := log.LoggerWithTrace(ctx, i.logger).With(zap.String("url", item.URL), zap.String("fileKey", item.Key()))
+
+	resp, err := i.fetchURL(ctx, ingestUpstream, item.URL, model.Preconditions{})
+	if err != nil {
+		logger.Warn("Error fetching image for ingest", zap.Error(err))
+		return nil, false, fmt.Errorf("%w: %w", ErrUpstream, err)
+	}
+	if !i.isValidImageResponse(resp) {
+		logger.Warn("Ingest source is not an image", zap.String("content_type", resp.contentType))
+		return nil, false, fmt.Errorf("%w: %s returned %q, not an image", ErrUpstream, item.URL, resp.contentType)
+	}
+
+	return i.Upload(ctx, item.UploadRequest, resp.contentType, resp.rawBytes, item.Replace)
+}
+
+// StartIngest запускает фоновую загрузку пачки изображений и сразу возвращает задачу для опроса статуса
+func (i *ImageService) StartIngest(items []model.IngestItem) model.Job {
+	keys := make([]string, len(items))
+	for idx, item := range items {
+		keys[idx] = item.Key()
+	}
+
+	job := i.jobs.create("ingest", keys)
+	go i.runJob(job, ingestConcurrency, ingestTimeout, func(ctx context.Context, idx int) (*model.ImageInfo, error) {
+		info, _, err := i.Ingest(ctx, items[idx])
+		return info, err
+	})
+
+	return job
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"resizer/api/model"
+	"resizer/converter/image"
+
+	"go.uber.org/zap"
+)
+
+// jobRetention - сколько завершенная задача доступна для опроса статуса
+const jobRetention = time.Hour
+
+// jobStore хранит фоновые задачи в памяти процесса, после перезапуска они теряются
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*model.Job
+}
+
+func newJobStore() *jobStore {
+	return &jobStore{jobs: make(map[string]*model.Job)}
+}
+
+// create регистрирует задачу с элементами keys и заодно удаляет давно завершенные
+func (s *jobStore) create(kind string, keys []string) model.Job {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	job := &model.Job{
+		ID:        hex.EncodeToString(id),
+		Kind:      kind,
+		Status:    model.JobPending,
+		Total:     len(keys),
+		Items:     make([]model.JobItem, len(keys)),
+		CreatedAt: time.Now(),
+	}
+	for idx, key := range keys {
+		job.Items[idx] = model.JobItem{Key: key, Status: model.JobPending}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for jobID, existing := range s.jobs {
+		if existing.FinishedAt != nil && time.Since(*existing.FinishedAt) > jobRetention {
+			delete(s.jobs, jobID)
+		}
+	}
+	s.jobs[job.ID] = job
+
+	return s.snapshot(job)
+}
+
+func (s *jobStore) get(id string) (model.Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return model.Job{}, false
+	}
+
+	return s.snapshot(job), true
+}
+
+// update меняет задачу под блокировкой
+func (s *jobStore) update(id string, fn func(job *model.Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		fn(job)
+	}
+}
+
+// snapshot копирует задачу, чтобы ее можно было отдать, пока она выполняется
+func (s *jobStore) snapshot(job *model.Job) model.Job {
+	copied := *job
+	copied.Items = slices.Clone(job.Items)
+
+	return copied
+}
+
+// runJob выполняет элементы задачи в фоне, не больше concurrency одновременно. Каждый элемент
+// получает свой таймаут, а ошибка одного элемента не останавливает остальные
+func (i *ImageService) runJob(job model.Job, concurrency int, timeout time.Duration, fn func(ctx context.Context, idx int) (*model.ImageInfo, error)) {
+	logger := i.logger.With(zap.String("job", job.ID), zap.String("kind", job.Kind))
+	i.jobs.update(job.ID, func(job *model.Job) { job.Status = model.JobRunning })
+
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for idx := range job.Items {
+		semaphore <- struct{}{}
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			i.jobs.update(job.ID, func(job *model.Job) { job.Items[idx].Status = model.JobRunning })
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			info, err := fn(ctx, idx)
+
+			i.jobs.update(job.ID, func(job *model.Job) {
+				item := &job.Items[idx]
+				item.Info = info
+				item.Status = model.JobDone
+				job.Completed++
+				if err != nil {
+					item.Status, item.Error, item.Code = model.JobFailed, err.Error(), errorCode(err)
+					job.Failed++
+				}
+			})
+			if err != nil {
+				logger.Warn("Job item failed", zap.String("key", job.Items[idx].Key), zap.Error(err))
+			}
+		}()
+	}
+	wg.Wait()
+
+	i.jobs.update(job.ID, func(job *model.Job) {
+		now := time.Now()
+		job.FinishedAt = &now
+		job.Status = model.JobDone
+		if job.Failed > 0 {
+			job.Status = model.JobFailed
+		}
+		logger.Info("Job finished", zap.Int("total", job.Total), zap.Int("failed", job.Failed))
+	})
+}
+
+// Job возвращает текущее состояние фоновой задачи
+func (i *ImageService) Job(id string) (model.Job, error) {
+	job, ok := i.jobs.get(id)
+	if !ok {
+		return model.Job{}, fmt.Errorf("%w: job %s", ErrNotFound, id)
+	}
+
+	return job, nil
+}
+
+// errorCode возвращает машиночитаемый код ошибки элемента задачи
+func errorCode(err error) string {
+	var limitErr *image.LimitError
+	switch {
+	case errors.As(err, &limitErr):
+		return limitErr.Code
+	case errors.Is(err, ErrAlreadyExists):
+		return "already_exists"
+	case errors.Is(err, ErrBlockedDestination):
+		return CodeBlockedDestination
+	case errors.Is(err, ErrUpstream):
+		return "upstream_error"
+	}
+
+	return ""
+}
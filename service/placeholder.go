@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+
+	"resizer/api/model"
+	"resizer/converter/image"
+)
+
+const placeholderName = "placeholder"
+
+// OriginalPlaceholder возвращает BlurHash и LQIP для оригинала
+func (i *ImageService) OriginalPlaceholder(ctx context.Context, entity, file string) (*model.Placeholder, error) {
+	return derivedJSON(ctx, i, i.originalSource(entity, file), placeholderName, buildPlaceholder)
+}
+
+// ProxyPlaceholder возвращает BlurHash и LQIP для проксируемого изображения
+func (i *ImageService) ProxyPlaceholder(ctx context.Context, serviceType model.ServiceName, rawPath string) (*model.Placeholder, error) {
+	return derivedJSON(ctx, i, i.proxySource(serviceType, rawPath), placeholderName, buildPlaceholder)
+}
+
+func buildPlaceholder(ci *image.CustomImage) (model.Placeholder, error) {
+	placeholder, err := ci.Placeholder()
+	if err != nil {
+		return model.Placeholder{}, err
+	}
+
+	return model.Placeholder{
+		BlurHash: placeholder.BlurHash,
+		LQIP:     placeholder.LQIP,
+		Width:    placeholder.Width,
+		Height:   placeholder.Height,
+	}, nil
+}
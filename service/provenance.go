@@ -0,0 +1,45 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"resizer/api/model"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// provenanceMetadata возвращает метаданные происхождения для копии апстрима. S3 передает их в заголовках,
+// поэтому исходный URL сохраняется в экранированном виде, а валидаторы апстрима - как он их прислал
+func provenanceMetadata(resp *ProxyResponse, sourceURL string, fetchedAt time.Time) map[string]*string {
+	metadata := map[string]*string{metaFetchedAt: aws.String(fetchedAt.UTC().Format(time.RFC3339))}
+
+	if u, err := url.Parse(sourceURL); err == nil {
+		metadata[metaSource] = aws.String(u.String())
+	}
+	if resp.UpstreamETag != "" {
+		metadata[metaUpstreamETag] = aws.String(resp.UpstreamETag)
+	}
+	if !resp.UpstreamLastModified.IsZero() {
+		metadata[metaUpstreamLastModified] = aws.String(resp.UpstreamLastModified.UTC().Format(http.TimeFormat))
+	}
+
+	return metadata
+}
+
+// readProvenance заполняет происхождение копии из метаданных S3. У объектов, сохраненных до появления
+// метаданных, поля остаются пустыми
+func (r *ProxyResponse) readProvenance(metadata map[string]*string) {
+	r.metadata = metadata
+	r.Provenance = provenanceFromMetadata(metadata)
+	r.Provenance.Cache = model.CacheHit
+	r.UpstreamETag = metadataString(metadata, metaUpstreamETag)
+	r.UpstreamLastModified, _ = http.ParseTime(metadataString(metadata, metaUpstreamLastModified))
+}
+
+func provenanceFromMetadata(metadata map[string]*string) model.Provenance {
+	cachedAt, _ := time.Parse(time.RFC3339, metadataString(metadata, metaFetchedAt))
+
+	return model.Provenance{Source: metadataString(metadata, metaSource), CachedAt: cachedAt}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"resizer/api/model"
+	"resizer/converter/image"
+	"resizer/shared/log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.uber.org/zap"
+)
+
+// PurgeProxy удаляет закешированное изображение апстрима вместе с его вариантами,
+// следующий запрос загрузит его заново
+func (i *ImageService) PurgeProxy(ctx context.Context, serviceType model.ServiceName, rawPath string) (*model.PurgeResponse, error) {
+	logger := log.LoggerWithTrace(ctx, i.logger)
+
+	key, err := proxyKey(serviceType, rawPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Варианты сверяются с пространством кеша прокси так же, как ключ: оригиналы удаляться не должны ни при каком пути
+	serviceRoot := path.Join("proxy", serviceType.String())
+	variants := variantPrefix(serviceRoot, rawPath)
+	if !strings.HasPrefix(variants, "variants/"+serviceRoot+"/") {
+		return nil, &image.LimitError{Code: model.CodeInvalidKey, Message: fmt.Sprintf("path %q escapes the proxy cache", rawPath)}
+	}
+
+	response := &model.PurgeResponse{Prefixes: []string{key}}
+
+	// Удаление отсутствующего объекта в S3 не считается ошибкой, поэтому наличие проверяется отдельно
+	_, err = i.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: &i.config.S3Bucket, Key: aws.String(key)})
+	if err != nil && !isNotFoundError(err) {
+		logger.Error("Error getting proxied image metadata from S3", zap.Error(err), zap.String("key", key))
+		return nil, err
+	}
+	if err == nil {
+		if _, err = i.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: &i.config.S3Bucket, Key: aws.String(key)}); err != nil {
+			logger.Error("Error deleting proxied image from S3", zap.Error(err), zap.String("key", key))
+			return nil, err
+		}
+		response.Purged++
+	}
+
+	response, err = i.purgePrefixes(ctx, response, variants)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Proxied image purged", zap.String("key", key), zap.Int("purged", response.Purged))
+
+	return response, nil
+}
+
+// PurgeVariants удаляет все варианты и производные данные оригинала, сам оригинал остается
+func (i *ImageService) PurgeVariants(ctx context.Context, entity, file string) (*model.PurgeResponse, error) {
+	return i.purgePrefixes(ctx, &model.PurgeResponse{}, variantPrefix(entity, file))
+}
+
+// PurgePrefix удаляет кеш по префиксу. Для префикса кеша прокси удаляются и варианты закешированных изображений
+func (i *ImageService) PurgePrefix(ctx context.Context, prefix string) (*model.PurgeResponse, error) {
+	prefixes := []string{prefix}
+	if strings.HasPrefix(prefix, "proxy/") {
+		prefixes = append(prefixes, "variants/"+prefix)
+	}
+
+	return i.purgePrefixes(ctx, &model.PurgeResponse{}, prefixes...)
+}
+
+// purgePrefixes удаляет объекты по префиксам, добавляя их к ответу, и очищает кеш libvips
+func (i *ImageService) purgePrefixes(ctx context.Context, response *model.PurgeResponse, prefixes ...string) (*model.PurgeResponse, error) {
+	logger := log.LoggerWithTrace(ctx, i.logger)
+	defer image.DropVipsCache()
+
+	for _, prefix := range prefixes {
+		purged, err := i.deletePrefix(ctx, prefix)
+		response.Purged += purged
+		if err != nil {
+			logger.Error("Error purging prefix from S3", zap.Error(err), zap.String("prefix", prefix), zap.Int("purged", purged))
+			return nil, err
+		}
+
+		response.Prefixes = append(response.Prefixes, prefix)
+		logger.Info("Prefix purged", zap.String("prefix", prefix), zap.Int("purged", purged))
+	}
+
+	return response, nil
+}
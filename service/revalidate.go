@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"resizer/api/model"
+	"resizer/config"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.uber.org/zap"
+)
+
+// isStale сообщает, что копия из S3 старше CACHE_TTL. Объекты без времени сохранения считаются устаревшими,
+// поэтому старый кеш один раз перепроверяется и получает метку
+func (i *ImageService) isStale(resp *ProxyResponse) bool {
+	if i.config.CacheTTL <= 0 || resp.StatusCode == http.StatusNotModified {
+		return false
+	}
+
+	return time.Since(resp.Provenance.CachedAt) > i.config.CacheTTL
+}
+
+// revalidateInBackground перепроверяет копию в фоне. Одновременно для ключа выполняется одна перепроверка
+func (i *ImageService) revalidateInBackground(serviceType model.ServiceName, rawPath string, cached *ProxyResponse) {
+	key := path.Join("proxy", serviceType.String(), rawPath)
+	if _, busy := i.revalidating.LoadOrStore(key, struct{}{}); busy {
+		return
+	}
+
+	i.cacheWG.Add(1)
+	go func() {
+		defer i.cacheWG.Done()
+		defer i.revalidating.Delete(key)
+
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		defer cancel()
+		i.revalidate(ctx, serviceType, rawPath, cached)
+	}()
+}
+
+// revalidate делает условный запрос к апстриму с его ETag и Last-Modified, а без них - со временем сохранения копии.
+// Новое изображение заменяет копию, при 304 и временных ошибках у копии обновляется время сохранения,
+// чтобы апстрим не опрашивался на каждый запрос. При 404 копия остается или удаляется по CACHE_GONE_POLICY
+func (i *ImageService) revalidate(ctx context.Context, serviceType model.ServiceName, rawPath string, cached *ProxyResponse) {
+	key := path.Join("proxy", serviceType.String(), rawPath)
+	url := serviceType.ToProxyURL(i.config.TMDBImageProxy) + rawPath
+	logger := i.logger.With(zap.String("key", key), zap.String("url", url))
+
+	cond := model.Preconditions{IfNoneMatch: cached.UpstreamETag, IfModifiedSince: cached.UpstreamLastModified}
+	if cond.IfModifiedSince.IsZero() {
+		cond.IfModifiedSince = cached.Provenance.CachedAt
+	}
+
+	resp, err := i.fetchURL(ctx, serviceType.String(), url, cond)
+
+	var statusErr *upstreamStatusError
+	switch {
+	case errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusGone):
+		if i.config.CacheGonePolicy != config.CacheGoneDelete {
+			logger.Info("апстрим больше не отдает изображение, оставляем копию")
+			break
+		}
+
+		logger.Info("апстрим больше не отдает изображение, удаляем копию")
+		i.deleteFromS3(i.config.S3Bucket, key)
+		if _, err = i.deletePrefix(ctx, variantPrefix(path.Join("proxy", serviceType.String()), rawPath)); err != nil {
+			logger.Warn("ошибка удаления вариантов", zap.Error(err))
+		}
+		return
+	case err != nil:
+		logger.Warn("ошибка перепроверки, оставляем копию", zap.Error(err))
+	case resp.StatusCode == http.StatusNotModified:
+		logger.Debug("изображение в апстриме не изменилось")
+	case !i.isValidImageResponse(resp):
+		logger.Warn("апстрим вернул не изображение, оставляем копию", zap.String("content_type", resp.contentType))
+	default:
+		logger.Info("изображение в апстриме обновилось, заменяем копию")
+		i.cacheInS3(i.config.S3Bucket, key, resp, url)
+		return
+	}
+
+	if err = i.touch(ctx, key, cached); err != nil {
+		logger.Warn("ошибка обновления времени сохранения", zap.Error(err))
+	}
+}
+
+// touch обновляет время сохранения копии, копируя объект в себя с новыми метаданными.
+// Тело не передается, а ETag однократно загруженного объекта не меняется
+func (i *ImageService) touch(ctx context.Context, key string, cached *ProxyResponse) error {
+	metadata := maps.Clone(cached.metadata)
+	if metadata == nil {
+		metadata = map[string]*string{}
+	}
+	maps.DeleteFunc(metadata, func(name string, _ *string) bool {
+		return strings.EqualFold(name, metaFetchedAt)
+	})
+	metadata[metaFetchedAt] = aws.String(time.Now().UTC().Format(time.RFC3339))
+
+	// CopySource экранируется как путь: слеши ключа должны остаться разделителями
+	copySource := (&url.URL{Path: i.config.S3Bucket + "/" + key}).EscapedPath()
+	_, err := i.s3.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+		Bucket:            &i.config.S3Bucket,
+		Key:               aws.String(key),
+		CopySource:        aws.String(copySource),
+		ContentType:       aws.String(cached.contentType),
+		Metadata:          metadata,
+		MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
+	})
+
+	return err
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"resizer/api/model"
+	"resizer/converter/image"
+	"resizer/converter/image/format"
+	"resizer/shared/log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.uber.org/zap"
+)
+
+// uploadQuality - качество перекодирования при нормализации оригинала
+const uploadQuality = 90
+
+var ErrAlreadyExists = errors.New("object already exists in S3")
+
+// Upload проверяет содержимое через декодер и сохраняет оригинал <entity>/<file> и сообщает, был ли он создан.
+// С replace существующий оригинал перезаписывается, а его варианты удаляются
+func (i *ImageService) Upload(ctx context.Context, req model.UploadRequest, contentType string, buf []byte, replace bool) (*model.ImageInfo, bool, error) {
+	key := req.Key()
+	logger := log.LoggerWithTrace(ctx, i.logger).With(zap.String("fileKey", key))
+
+	if i.isHTMLContent(contentType, buf) || !i.isValidImageBySignature(buf) {
+		return nil, false, &image.LimitError{Code: model.CodeNotImage, Message: "content is not a supported image"}
+	}
+	kind := image.DetectFormat(buf)
+
+	// HEAD позволяет не декодировать изображение зря и отличить создание от замены
+	_, err := i.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: &i.config.S3Bucket, Key: aws.String(key)})
+	exists := err == nil
+	if err != nil && !isNotFoundError(err) {
+		logger.Error("Error getting original metadata from S3", zap.Error(err))
+		return nil, false, err
+	}
+	if exists && !replace {
+		return nil, false, fmt.Errorf("%w: %s", ErrAlreadyExists, key)
+	}
+
+	buf, err = i.decodeUpload(ctx, req, kind, buf)
+	if err != nil {
+		logger.Warn("Uploaded image rejected", zap.Error(err))
+		return nil, false, err
+	}
+
+	metadata := map[string]*string{}
+	if size, err := image.ReadHeader(buf); err == nil {
+		metadata[metaWidth] = aws.String(strconv.Itoa(size.Width))
+		metadata[metaHeight] = aws.String(strconv.Itoa(size.Height))
+	}
+
+	// Между HEAD и записью оригинал может создать другой запрос, поэтому без replace запись условная:
+	// с If-None-Match: * из двух одновременных созданий успешно только одно. Хранилища без условной записи
+	// заголовок игнорируют, и тогда второе создание перезапишет первое
+	var opts []request.Option
+	if !replace {
+		opts = append(opts, request.WithSetRequestHeaders(map[string]string{"If-None-Match": "*"}))
+	}
+
+	_, err = i.s3.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      &i.config.S3Bucket,
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(buf),
+		ContentType: aws.String("image/" + kind),
+		Metadata:    metadata,
+	}, opts...)
+	if isConditionFailedError(err) {
+		return nil, false, fmt.Errorf("%w: %s", ErrAlreadyExists, key)
+	}
+	if err != nil {
+		logger.Error("Error uploading original to S3", zap.Error(err))
+		return nil, false, err
+	}
+
+	// Варианты старого оригинала уже не используются из-за смены ETag, удаляем их, чтобы не занимали место
+	if exists {
+		if purged, err := i.deletePrefix(ctx, variantPrefix(req.Entity, req.File)); err != nil {
+			logger.Warn("Error purging variants of replaced original", zap.Error(err))
+		} else {
+			logger.Debug("Variants of replaced original purged", zap.Int("count", purged))
+		}
+	}
+
+	logger.Info("Original uploaded", zap.String("format", kind), zap.Int("size", len(buf)), zap.Bool("created", !exists))
+
+	info, err := inspect(key, buf)
+	return info, !exists, err
+}
+
+// decodeUpload декодирует загружаемое изображение с проверкой лимитов. При нормализации
+// оно уменьшается до максимального размера и перекодируется в исходный формат
+func (i *ImageService) decodeUpload(ctx context.Context, req model.UploadRequest, kind string, buf []byte) ([]byte, error) {
+	var encoder image.Encoder
+	if req.Normalize() {
+		var t image.Type
+		if err := t.UnmarshalText([]byte(kind)); err != nil || !i.strategy.CanSave(t) {
+			return nil, &image.LimitError{Code: image.CodeUnsupported, Message: fmt.Sprintf("%s originals cannot be normalized", kind)}
+		}
+		encoder = i.strategy.Apply(t)
+	}
+
+	// Без удаления метаданные оригинала сохраняются как есть, без перевода в sRGB
+	meta := format.Metadata{}
+	if req.Strip {
+		meta = i.metadata
+		meta.Strip = true
+	}
+
+	var normalized io.Reader
+	err := i.pool.Do(ctx, func() error {
+		customImage := image.NewCustomImage(encoder, i.limits, meta)
+		if err := customImage.Decode(bytes.NewReader(buf)); err != nil {
+			return err
+		}
+		if encoder == nil {
+			return nil
+		}
+
+		var err error
+		width := customImage.BoundedWidth(req.MaxWidth, req.MaxHeight)
+		if customImage.IsAnimated() && customImage.CanAnimate() {
+			normalized, _, err = customImage.EncodeAnimated(ctx, width, uploadQuality, image.Overrides{})
+		} else {
+			customImage.Transform(image.WithWidth(width))
+			normalized, _, err = customImage.Encode(ctx, uploadQuality, image.Overrides{})
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if normalized == nil {
+		return buf, nil
+	}
+
+	return io.ReadAll(normalized)
+}
+
+// DeleteOriginal удаляет оригинал вместе со всеми его вариантами и производными данными
+func (i *ImageService) DeleteOriginal(ctx context.Context, entity, file string) (*model.DeleteResponse, error) {
+	key := fmt.Sprintf("%s/%s", entity, file)
+	logger := log.LoggerWithTrace(ctx, i.logger).With(zap.String("fileKey", key))
+
+	_, err := i.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: &i.config.S3Bucket, Key: aws.String(key)})
+	if isNotFoundError(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		logger.Error("Error getting original metadata from S3", zap.Error(err))
+		return nil, err
+	}
+
+	if _, err = i.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: &i.config.S3Bucket, Key: aws.String(key)}); err != nil {
+		logger.Error("Error deleting original from S3", zap.Error(err))
+		return nil, err
+	}
+
+	purged, err := i.deletePrefix(ctx, variantPrefix(entity, file))
+	if err != nil {
+		logger.Error("Error purging variants from S3", zap.Error(err))
+		return nil, err
+	}
+
+	logger.Info("Original deleted", zap.Int("purgedVariants", purged))
+
+	return &model.DeleteResponse{Key: key, PurgedVariants: purged}, nil
+}
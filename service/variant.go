@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"resizer/api/model"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	metaWidth  = "width"
+	metaHeight = "height"
+
+	// Происхождение копии апстрима: время сохранения в кеш, валидаторы апстрима для перепроверки и исходный URL
+	metaFetchedAt            = "fetched-at"
+	metaUpstreamETag         = "upstream-etag"
+	metaUpstreamLastModified = "upstream-last-modified"
+	metaSource               = "source"
+)
+
+var errNoVariant = errors.New("image has no cacheable variant")
+
+// variantPrefix возвращает общий префикс всех вариантов оригинала
+func variantPrefix(entity, file string) string {
+	return path.Join("variants", entity, file) + "/"
+}
+
+// variantKey возвращает ключ варианта. ETag оригинала входит в ключ,
+// поэтому после замены оригинала старые варианты просто перестают использоваться
+func variantKey(params model.ImageRequest, etag string) string {
+	return variantPrefix(params.Entity, params.File) + strings.Trim(etag, `"`)
+}
+
+// deletePrefix удаляет все объекты с префиксом и возвращает их количество.
+// Страница ListObjectsV2 не больше 1000 ключей, поэтому каждая удаляется одним DeleteObjects
+func (i *ImageService) deletePrefix(ctx context.Context, prefix string) (int, error) {
+	var (
+		deleted   int
+		deleteErr error
+	)
+
+	input := &s3.ListObjectsV2Input{Bucket: &i.config.S3Bucket, Prefix: aws.String(prefix)}
+	err := i.s3.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		if len(page.Contents) == 0 {
+			return true
+		}
+
+		objects := make([]*s3.ObjectIdentifier, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			objects = append(objects, &s3.ObjectIdentifier{Key: object.Key})
+		}
+
+		result, err := i.s3.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: &i.config.S3Bucket,
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			deleteErr = err
+			return false
+		}
+
+		deleted += len(objects) - len(result.Errors)
+		if len(result.Errors) > 0 {
+			deleteErr = fmt.Errorf("failed to delete %d objects, first %s: %s",
+				len(result.Errors), aws.StringValue(result.Errors[0].Key), aws.StringValue(result.Errors[0].Message))
+			return false
+		}
+
+		return true
+	})
+	if err == nil {
+		err = deleteErr
+	}
+
+	return deleted, err
+}
+
+// getVariant получает закешированный вариант из S3
+func (i *ImageService) getVariant(ctx context.Context, params model.ImageRequest, sourceContentType, etag string, lastModified time.Time) (*model.ImageResponse, error) {
+	if !i.config.VariantCache || etag == "" || sourceContentType == "image/svg+xml" {
+		return nil, errNoVariant
+	}
+
+	key := variantKey(params, etag)
+	result, err := i.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: &i.config.S3Bucket, Key: &key})
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := metadataSize(result.Metadata)
+
+	return &model.ImageResponse{
+		Body:               result.Body,
+		ContentType:        params.Type.MIME(),
+		ContentLength:      aws.Int64Value(result.ContentLength),
+		ContentDisposition: fmt.Sprintf("inline; filename=%s.%s", path.Base(params.File), params.Type),
+		Type:               params.Type.String(),
+		ETag:               etag,
+		LastModified:       lastModified,
+		Width:              width,
+		Height:             height,
+	}, nil
+}
+
+// cacheVariant асинхронно сохраняет вариант в S3, если кеш вариантов включен
+func (i *ImageService) cacheVariant(params model.ImageRequest, etag string, buf []byte) {
+	if !i.config.VariantCache || etag == "" {
+		return
+	}
+
+	resp := &ProxyResponse{Headers: http.Header{}, rawBytes: buf, contentType: params.Type.MIME()}
+	i.cacheInBackground(i.config.S3Bucket, variantKey(params, etag), resp, fmt.Sprintf("%s/%s", params.Entity, params.File))
+}
+
+// metadataSize читает размеры изображения из пользовательских метаданных объекта
+func metadataSize(metadata map[string]*string) (width, height int) {
+	return metadataInt(metadata, metaWidth), metadataInt(metadata, metaHeight)
+}
+
+func metadataInt(metadata map[string]*string, name string) int {
+	n, _ := strconv.Atoi(metadataString(metadata, name))
+	return n
+}
+
+// metadataString ищет значение без учета регистра: SDK канонизирует имена метаданных
+func metadataString(metadata map[string]*string, name string) string {
+	for k, v := range metadata {
+		if strings.EqualFold(k, name) {
+			return aws.StringValue(v)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"resizer/api/model"
+	"resizer/converter/image"
+	"resizer/shared/log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.uber.org/zap"
+)
+
+// verifyHeaderBytes - сколько байтов начала объекта читается для проверки HTML, сигнатуры и заголовка
+const verifyHeaderBytes = 64 * 1024
+
+// Verify обходит объекты с префиксом и находит пустые, HTML-страницы, объекты с неизвестной сигнатурой,
+// нечитаемым заголовком или обрезанные при сохранении. Обычно читаются только начало и конец объекта.
+// Найденные объекты удаляются или переносятся в карантин в зависимости от действия
+func (i *ImageService) Verify(ctx context.Context, opts model.VerifyOptions) (*model.VerifyReport, error) {
+	logger := log.LoggerWithTrace(ctx, i.logger).With(zap.String("prefix", opts.Prefix), zap.String("action", string(opts.Action)))
+	report := &model.VerifyReport{Prefix: opts.Prefix, Action: opts.Action, Bad: []model.BadObject{}, StartedAt: time.Now()}
+
+	var throttle <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(opts.Rate))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	var scanErr error
+	input := &s3.ListObjectsV2Input{Bucket: &i.config.S3Bucket, Prefix: aws.String(opts.Prefix)}
+	err := i.s3.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, object := range page.Contents {
+			if throttle != nil {
+				select {
+				case <-ctx.Done():
+					scanErr = ctx.Err()
+					return false
+				case <-throttle:
+				}
+			}
+
+			key, size := aws.StringValue(object.Key), aws.Int64Value(object.Size)
+			reason, err := i.verifyObject(ctx, key, size)
+			if err != nil {
+				scanErr = fmt.Errorf("%s: %w", key, err)
+				return false
+			}
+
+			report.Scanned++
+			if reason == "" {
+				continue
+			}
+
+			report.Bad = append(report.Bad, model.BadObject{Key: key, Reason: reason, Size: size})
+			logger.Warn("Bad object found", zap.String("key", key), zap.String("reason", reason))
+
+			if scanErr = i.applyVerifyAction(ctx, opts, key, report); scanErr != nil {
+				return false
+			}
+		}
+
+		logger.Info("Verify in progress", zap.Int("scanned", report.Scanned), zap.Int("bad", len(report.Bad)))
+		return true
+	})
+	if err == nil {
+		err = scanErr
+	}
+
+	report.FinishedAt = time.Now()
+	if err != nil {
+		logger.Error("Verify stopped", zap.Error(err), zap.Int("scanned", report.Scanned))
+		return report, err
+	}
+
+	logger.Info("Prefix verified", zap.Int("scanned", report.Scanned), zap.Int("bad", len(report.Bad)),
+		zap.Int("deleted", report.Deleted), zap.Int("quarantined", report.Quarantined))
+
+	return report, nil
+}
+
+// verifyObject возвращает причину, по которой объект не является целым изображением, или пустую строку.
+// Производные JSON и объекты с не изображением в Content-Type (кроме HTML) не проверяются
+func (i *ImageService) verifyObject(ctx context.Context, key string, size int64) (string, error) {
+	if isDerivedKey(key) {
+		return "", nil
+	}
+	if size == 0 {
+		return "empty", nil
+	}
+
+	head, contentType, err := i.readRange(ctx, key, fmt.Sprintf("bytes=0-%d", verifyHeaderBytes-1))
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case i.isHTMLContent(contentType, head):
+		return "html", nil
+	case contentType == "image/svg+xml" || !mayBeImage(contentType):
+		return "", nil
+	case !i.isValidImageBySignature(head):
+		return "invalid_signature", nil
+	}
+
+	// Заголовок может не поместиться в прочитанное начало (ICC, XMP, миниатюра перед SOF в JPEG),
+	// поэтому битым объект считается, только если его не удалось декодировать целиком
+	if _, err = image.ReadHeader(head); err != nil {
+		if size > int64(len(head)) {
+			if head, _, err = i.readRange(ctx, key, ""); err != nil {
+				return "", err
+			}
+		}
+
+		// Размеры проверяются до декодирования, как и при обработке: иначе закешированная
+		// декомпрессионная бомба декодировалась бы в обход лимитов
+		imageSize, err := image.ReadHeader(head)
+		if err != nil {
+			return "undecodable", nil
+		}
+		if err = i.limits.CheckInput(imageSize); err != nil {
+			var limitErr *image.LimitError
+			if errors.As(err, &limitErr) && limitErr.Code == image.CodeInputTooLarge {
+				return "too_large", nil
+			}
+			return "undecodable", nil
+		}
+
+		err = i.pool.Do(ctx, func() error { return image.Verify(head) })
+		if errors.Is(err, image.ErrQueueFull) || errors.Is(err, image.ErrQueueTimeout) {
+			return "", err
+		}
+		if err != nil {
+			return "undecodable", nil
+		}
+	}
+
+	tail := head[max(0, len(head)-image.TrailerSize):]
+	if size > int64(len(head)) {
+		if tail, _, err = i.readRange(ctx, key, fmt.Sprintf("bytes=-%d", image.TrailerSize)); err != nil {
+			return "", err
+		}
+	}
+	if !image.IsComplete(image.DetectFormat(head), head, tail, size) {
+		return "truncated", nil
+	}
+
+	return "", nil
+}
+
+// mayBeImage сообщает, что по Content-Type объект может быть изображением: image/* или тип, ничего не говорящий о содержимом
+func mayBeImage(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch mediaType = strings.TrimSpace(strings.ToLower(mediaType)); mediaType {
+	case "", "application/octet-stream", "binary/octet-stream":
+		return true
+	}
+
+	return strings.HasPrefix(mediaType, "image/")
+}
+
+// readRange читает диапазон объекта, пустой rng - объект целиком
+func (i *ImageService) readRange(ctx context.Context, key, rng string) ([]byte, string, error) {
+	input := &s3.GetObjectInput{Bucket: &i.config.S3Bucket, Key: aws.String(key)}
+	if rng != "" {
+		input.Range = aws.String(rng)
+	}
+
+	result, err := i.s3.GetObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, "", err
+	}
+	defer result.Body.Close()
+
+	buf, err := io.ReadAll(result.Body)
+	return buf, aws.StringValue(result.ContentType), err
+}
+
+// applyVerifyAction удаляет найденный объект или переносит его в карантин с сохранением ключа
+func (i *ImageService) applyVerifyAction(ctx context.Context, opts model.VerifyOptions, key string, report *model.VerifyReport) error {
+	if opts.Action == model.VerifyDryRun {
+		return nil
+	}
+
+	if opts.Action == model.VerifyQuarantine {
+		// CopySource экранируется как путь: слеши ключа должны остаться разделителями
+		copySource := (&url.URL{Path: i.config.S3Bucket + "/" + key}).EscapedPath()
+		_, err := i.s3.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+			Bucket:     &i.config.S3Bucket,
+			CopySource: aws.String(copySource),
+			Key:        aws.String(opts.QuarantinePrefix + key),
+		})
+		if err != nil {
+			return fmt.Errorf("quarantine %s: %w", key, err)
+		}
+	}
+
+	if _, err := i.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: &i.config.S3Bucket, Key: aws.String(key)}); err != nil {
+		return fmt.Errorf("delete %s: %w", key, err)
+	}
+
+	if opts.Action == model.VerifyQuarantine {
+		report.Quarantined++
+	} else {
+		report.Deleted++
+	}
+
+	return nil
+}
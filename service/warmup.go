@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"resizer/api/model"
+
+	"go.uber.org/zap"
+)
+
+const (
+	warmupConcurrency = 4
+	warmupTimeout     = 5 * time.Minute
+)
+
+// StartWarmup запускает фоновый прогрев: изображения апстримов загружаются в кеш S3,
+// а для каждого источника строятся и сохраняются варианты. Без кеша вариантов строить их
+// незачем, поэтому прогреваются только изображения апстримов
+func (i *ImageService) StartWarmup(sources []model.ImageSource, variants []model.ImageRequest) model.Job {
+	if !i.config.VariantCache && len(variants) > 0 {
+		i.logger.Warn("VARIANT_CACHE is disabled, warmup variants are skipped", zap.Int("variants", len(variants)))
+		variants = nil
+	}
+
+	keys := make([]string, len(sources))
+	for idx, source := range sources {
+		keys[idx] = source.Key()
+	}
+
+	job := i.jobs.create("warmup", keys)
+	go i.runJob(job, warmupConcurrency, warmupTimeout, func(ctx context.Context, idx int) (*model.ImageInfo, error) {
+		return nil, i.warm(ctx, sources[idx], variants)
+	})
+
+	return job
+}
+
+// warm прогревает один источник. Ошибка варианта не останавливает построение остальных
+func (i *ImageService) warm(ctx context.Context, source model.ImageSource, variants []model.ImageRequest) error {
+	if source.Proxied {
+		return i.warmProxy(ctx, source, variants)
+	}
+
+	var errs []error
+	for _, params := range variants {
+		params.Entity, params.File = source.Entity, source.File
+
+		response, err := i.Process(ctx, params, model.Preconditions{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", params.VariantKey(), err))
+			continue
+		}
+		closeBody(response.Body)
+	}
+
+	return errors.Join(errs...)
+}
+
+// warmProxy загружает изображение апстрима и строит варианты из полученных байтов: сохранение
+// в S3 асинхронное, и ProcessProxy мог бы не найти его в кеше и загрузить изображение повторно
+func (i *ImageService) warmProxy(ctx context.Context, source model.ImageSource, variants []model.ImageRequest) error {
+	logger := i.logger.With(zap.String("key", source.Key()))
+
+	resp, err := i.ProxyImage(ctx, source.Service, source.File, model.Preconditions{}, model.Range{})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK || !i.isValidImageResponse(resp) {
+		return fmt.Errorf("%w: no image for %s, status %d", ErrUpstream, source.Key(), resp.StatusCode)
+	}
+
+	var errs []error
+	for _, params := range variants {
+		params.Entity, params.File = path.Join("proxy", source.Service.String()), source.File
+		etag := variantETag(resp.contentType, resp.ETag, params)
+
+		if cached, err := i.getVariant(ctx, params, resp.contentType, etag, resp.LastModified); err == nil {
+			closeBody(cached.Body)
+			logger.Debug("Variant is already cached", zap.String("etag", etag))
+			continue
+		}
+
+		if err = i.checkOutputType(resp.contentType, params); err == nil {
+			_, err = i.render(ctx, params, resp.contentType, resp.rawBytes, etag, resp.LastModified)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", params.VariantKey(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// closeBody закрывает тело ответа, если оно получено из S3
+func closeBody(body io.Reader) {
+	if closer, ok := body.(io.Closer); ok {
+		closer.Close()
+	}
+}